@@ -0,0 +1,496 @@
+package redis
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/macoli/gowrapper/table"
+)
+
+// ==================================big key and hot key==================================================
+
+// KeyStat 单个 key 的统计信息
+type KeyStat struct {
+	Instance string // redis 实例地址
+	Key      string
+	Type     string // string、hash、list、set、zset、stream 等
+	Memory   int64  // MEMORY USAGE 估算的内存(字节), 热 key 分析时为 0
+	Length   int64  // 元素数量, string 为字节长度, 不支持的类型为 0
+	Freq     int64  // OBJECT FREQ 的访问频率(对数计数器, 最大 255), 大 key 分析时为 0
+}
+
+// keyLengthCommands 获取各类型 key 元素数量的命令
+var keyLengthCommands = map[string]string{
+	"string": "strlen",
+	"hash":   "hlen",
+	"list":   "llen",
+	"set":    "scard",
+	"zset":   "zcard",
+	"stream": "xlen",
+}
+
+// KeyAnalysisOptions 大 key、热 key 分析选项
+type KeyAnalysisOptions struct {
+	Match    string        // SCAN MATCH 模式, 为空时分析所有 key
+	Count    int64         // SCAN COUNT, 小于等于 0 时为 100
+	Type     string        // SCAN TYPE, 只分析该类型的 key, redis 6 及以上版本才支持
+	Interval time.Duration // 每批之间的等待时间, 降低对节点的压力
+	TopN     int           // 每个排行保留的 key 数量, 小于等于 0 时为 10
+	Samples  int           // MEMORY USAGE SAMPLES, 估算集合类型内存时抽样的元素数量, 小于等于 0 时使用 redis 默认值 5, 只用于大 key 分析
+}
+
+// withDefaults 返回填充默认值后的分析选项
+func (o *KeyAnalysisOptions) withDefaults() *KeyAnalysisOptions {
+	ret := KeyAnalysisOptions{}
+	if o != nil {
+		ret = *o
+	}
+	if ret.Count <= 0 {
+		ret.Count = 100
+	}
+	if ret.TopN <= 0 {
+		ret.TopN = 10
+	}
+	return &ret
+}
+
+// topKeys 按 less 保留前 n 个 key
+type topKeys struct {
+	n     int
+	less  func(a, b *KeyStat) bool // a 排在 b 前面
+	items []*KeyStat
+}
+
+// add 加入 key, 超过 2n 个时排序截断
+func (t *topKeys) add(stat *KeyStat) {
+	t.items = append(t.items, stat)
+	if len(t.items) > 2*t.n {
+		t.trim()
+	}
+}
+
+// trim 排序并只保留前 n 个, n 小于 0 时视为 0
+func (t *topKeys) trim() []*KeyStat {
+	sort.SliceStable(t.items, func(i, j int) bool { return t.less(t.items[i], t.items[j]) })
+	n := t.n
+	if n < 0 {
+		n = 0
+	}
+	if len(t.items) > n {
+		t.items = t.items[:n]
+	}
+	return t.items
+}
+
+func byMemory(a, b *KeyStat) bool { return a.Memory > b.Memory }
+func byLength(a, b *KeyStat) bool { return a.Length > b.Length }
+func byFreq(a, b *KeyStat) bool   { return a.Freq > b.Freq }
+
+// analyzeKeys 通过 SCAN 遍历节点的 key, 每批 key 调用一次 fn, fn 通过 pipelined 在节点上批量执行命令
+func (p *NodePool) analyzeKeys(ctx context.Context, addr string, opts *KeyAnalysisOptions,
+	fn func(keys []string, pipelined func(queue func(ctx context.Context, pipe redis.Pipeliner)) error) error) error {
+	rc, release, err := p.acquire(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// pipelined 通过 pipeline 执行 queue 添加的命令,失败时按重试策略重试(重试时重新调用 queue),
+	// key 在 SCAN 之后被删除导致的 redis.Nil 错误被忽略
+	pipelined := func(queue func(ctx context.Context, pipe redis.Pipeliner)) error {
+		err := p.retry(ctx, addr, "PIPELINE", func() error {
+			pipeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			cmds, _ := rc.Pipelined(pipeCtx, func(pipe redis.Pipeliner) error {
+				queue(pipeCtx, pipe)
+				return nil
+			})
+			for _, cmd := range cmds {
+				if err := cmd.Err(); err != nil && err != redis.Nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return &NodeError{Addr: addr, Op: "PIPELINE", Err: err}
+		}
+		return nil
+	}
+
+	it := NewKeyIterator(p, addr)
+	it.Match, it.Count, it.Type, it.Interval = opts.Match, opts.Count, opts.Type, opts.Interval
+	return it.Each(ctx, func(addr string, keys []string) error {
+		return fn(keys, pipelined)
+	})
+}
+
+// queueKeyTypes 在 pipeline 中添加获取每个 key 类型的命令, key 已经不存在时类型为 none
+func queueKeyTypes(ctx context.Context, pipe redis.Pipeliner, keys []string) []*redis.StatusCmd {
+	types := make([]*redis.StatusCmd, 0, len(keys))
+	for _, key := range keys {
+		types = append(types, pipe.Type(ctx, key))
+	}
+	return types
+}
+
+// ==================================big key==================================================
+
+// NodeBigKeys 单个节点的大 key 分析结果
+type NodeBigKeys struct {
+	Addr       string
+	Keys       int64                 // 分析的 key 数量
+	TypeCounts map[string]int64      // 各类型 key 的数量
+	TypeMemory map[string]int64      // 各类型 key 的总内存(字节)
+	TopMemory  []*KeyStat            // 内存最大的 key
+	TopLength  map[string][]*KeyStat // 各类型元素数量最多的 key {type: keys}
+}
+
+// BigKeyReport 多个节点的大 key 分析结果
+type BigKeyReport struct {
+	Nodes  []*NodeBigKeys   // 分析成功的节点, 按地址排序
+	Errors map[string]error // 分析失败的节点 {addr: err}
+}
+
+// TopMemory 获取所有节点中内存最大的 n 个 key
+func (r *BigKeyReport) TopMemory(n int) []*KeyStat {
+	t := &topKeys{n: n, less: byMemory}
+	for _, node := range r.Nodes {
+		t.items = append(t.items, node.TopMemory...)
+	}
+	return t.trim()
+}
+
+// TopLength 获取所有节点中每种类型元素数量最多的 n 个 key {type: keys}
+func (r *BigKeyReport) TopLength(n int) map[string][]*KeyStat {
+	tops := make(map[string]*topKeys)
+	for _, node := range r.Nodes {
+		for typ, stats := range node.TopLength {
+			if _, ok := tops[typ]; !ok {
+				tops[typ] = &topKeys{n: n, less: byLength}
+			}
+			tops[typ].items = append(tops[typ].items, stats...)
+		}
+	}
+	ret := make(map[string][]*KeyStat, len(tops))
+	for typ, t := range tops {
+		ret[typ] = t.trim()
+	}
+	return ret
+}
+
+// ShowTable 通过表格展示所有节点中内存最大的 n 个 key,以及每种类型元素数量最多的 n 个 key,
+// RANKING 列为 memory 时表示按内存排行, 为 length:<type> 时表示按该类型的元素数量排行
+func (r *BigKeyReport) ShowTable(n int) {
+	var rows []interface{}
+	addRows := func(ranking string, stats []*KeyStat) {
+		for _, stat := range stats {
+			rows = append(rows, []string{ranking, stat.Instance, stat.Key, stat.Type,
+				strconv.FormatInt(stat.Memory, 10), strconv.FormatInt(stat.Length, 10)})
+		}
+	}
+
+	addRows("memory", r.TopMemory(n))
+	tops := r.TopLength(n)
+	types := make([]string, 0, len(tops))
+	for typ := range tops {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		addRows("length:"+typ, tops[typ])
+	}
+	headers := []string{"RANKING", "INSTANCE", "KEY", "TYPE", "MEMORY", "LENGTH"}
+	table.ShowTable(table.GenHeaderCellsByNames(headers), table.GenBodyCells(rows))
+}
+
+// BigKeys 并发分析多个节点的大 key,详见 NodePool.BigKeys
+func BigKeys(addrSlice []string, password string, opts *KeyAnalysisOptions) (*BigKeyReport, error) {
+	return BigKeysContext(context.Background(), addrSlice, password, opts)
+}
+
+// BigKeysContext 并发分析多个节点的大 key, ctx 取消时立即返回
+func BigKeysContext(ctx context.Context, addrSlice []string, password string, opts *KeyAnalysisOptions) (*BigKeyReport, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.BigKeys(ctx, addrSlice, opts)
+}
+
+// BigKeys 通过连接池并发分析多个节点的大 key
+/*
+1.每个节点通过 SCAN 遍历 key,每批 key 通过 pipeline 获取 TYPE,再获取 MEMORY USAGE 和元素数量(STRLEN、HLEN、LLEN、SCARD、ZCARD、XLEN)
+2.每个节点分别保留内存最大和每种类型元素数量最多的 TopN 个 key
+3.可以传入 slave 地址以避免影响 master, 部分节点失败时返回其他节点的结果和失败节点的错误,所有节点都失败时返回错误
+*/
+func (p *NodePool) BigKeys(ctx context.Context, addrSlice []string, opts *KeyAnalysisOptions) (ret *BigKeyReport, err error) {
+	defer logOperation(p.logger(), "BigKeys", "nodes", len(addrSlice))(&err)
+
+	opts = opts.withDefaults()
+	nodes := make([]*NodeBigKeys, len(addrSlice))
+	errs := make([]error, len(addrSlice))
+	eachNode(addrSlice, true, func(i int, addr string) error {
+		nodes[i], errs[i] = p.nodeBigKeys(ctx, addr, opts)
+		return errs[i]
+	})
+
+	ret = &BigKeyReport{Errors: make(map[string]error)}
+	for i, addr := range addrSlice {
+		if errs[i] != nil {
+			ret.Errors[addr] = errs[i]
+			continue
+		}
+		ret.Nodes = append(ret.Nodes, nodes[i])
+	}
+	if len(addrSlice) > 0 && len(ret.Errors) == len(addrSlice) {
+		return nil, errs[0]
+	}
+	sort.Slice(ret.Nodes, func(i, j int) bool { return ret.Nodes[i].Addr < ret.Nodes[j].Addr })
+	return ret, nil
+}
+
+// ClusterBigKeys 并发分析集群所有 master 的大 key
+func ClusterBigKeys(data *ClusterInfo, password string, opts *KeyAnalysisOptions) (*BigKeyReport, error) {
+	return ClusterBigKeysContext(context.Background(), data, password, opts)
+}
+
+// ClusterBigKeysContext 并发分析集群所有 master 的大 key, ctx 取消时立即返回
+func ClusterBigKeysContext(ctx context.Context, data *ClusterInfo, password string, opts *KeyAnalysisOptions) (*BigKeyReport, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterBigKeys(ctx, data, opts)
+}
+
+// ClusterBigKeys 通过连接池并发分析集群所有 master 的大 key
+func (p *NodePool) ClusterBigKeys(ctx context.Context, data *ClusterInfo, opts *KeyAnalysisOptions) (*BigKeyReport, error) {
+	return p.BigKeys(ctx, clusterMasterAddrs(data), opts)
+}
+
+// clusterMasterAddrs 获取集群所有 master 的地址
+func clusterMasterAddrs(data *ClusterInfo) (addrs []string) {
+	for _, node := range clusterGroupNodes(data) {
+		if node.role == "master" {
+			addrs = append(addrs, node.addr)
+		}
+	}
+	return addrs
+}
+
+// nodeBigKeys 分析单个节点的大 key
+func (p *NodePool) nodeBigKeys(ctx context.Context, addr string, opts *KeyAnalysisOptions) (*NodeBigKeys, error) {
+	node := &NodeBigKeys{
+		Addr:       addr,
+		TypeCounts: make(map[string]int64),
+		TypeMemory: make(map[string]int64),
+		TopLength:  make(map[string][]*KeyStat),
+	}
+	topMemory := &topKeys{n: opts.TopN, less: byMemory}
+	topLength := make(map[string]*topKeys)
+
+	var samples []int
+	if opts.Samples > 0 {
+		samples = []int{opts.Samples}
+	}
+	err := p.analyzeKeys(ctx, addr, opts, func(keys []string, pipelined func(queue func(ctx context.Context, pipe redis.Pipeliner)) error) error {
+		// 获取类型
+		var typeCmds []*redis.StatusCmd
+		err := pipelined(func(ctx context.Context, pipe redis.Pipeliner) {
+			typeCmds = queueKeyTypes(ctx, pipe, keys)
+		})
+		if err != nil {
+			return err
+		}
+
+		// 根据类型获取内存和元素数量
+		var memory, length []*redis.IntCmd
+		err = pipelined(func(ctx context.Context, pipe redis.Pipeliner) {
+			memory = make([]*redis.IntCmd, len(keys))
+			length = make([]*redis.IntCmd, len(keys))
+			for i, key := range keys {
+				if typeCmds[i].Val() == "none" {
+					continue
+				}
+				memory[i] = pipe.MemoryUsage(ctx, key, samples...)
+				if name, ok := keyLengthCommands[typeCmds[i].Val()]; ok {
+					length[i] = redis.NewIntCmd(ctx, name, key)
+					pipe.Process(ctx, length[i])
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		for i, key := range keys {
+			if memory[i] == nil || memory[i].Err() != nil {
+				continue
+			}
+			stat := &KeyStat{Instance: addr, Key: key, Type: typeCmds[i].Val(), Memory: memory[i].Val()}
+			node.Keys++
+			node.TypeCounts[stat.Type]++
+			node.TypeMemory[stat.Type] += stat.Memory
+			topMemory.add(stat)
+			if length[i] != nil && length[i].Err() == nil {
+				stat.Length = length[i].Val()
+				if _, ok := topLength[stat.Type]; !ok {
+					topLength[stat.Type] = &topKeys{n: opts.TopN, less: byLength}
+				}
+				topLength[stat.Type].add(stat)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	node.TopMemory = topMemory.trim()
+	for typ, t := range topLength {
+		node.TopLength[typ] = t.trim()
+	}
+	return node, nil
+}
+
+// ==================================hot key==================================================
+
+// NodeHotKeys 单个节点的热 key 分析结果
+type NodeHotKeys struct {
+	Addr   string
+	Policy string     // maxmemory-policy
+	Keys   int64      // 分析的 key 数量
+	Top    []*KeyStat // 访问频率最高的 key
+}
+
+// HotKeyReport 多个节点的热 key 分析结果
+type HotKeyReport struct {
+	Nodes  []*NodeHotKeys   // 分析成功的节点, 按地址排序
+	Errors map[string]error // 分析失败的节点 {addr: err}
+}
+
+// Top 获取所有节点中访问频率最高的 n 个 key
+func (r *HotKeyReport) Top(n int) []*KeyStat {
+	t := &topKeys{n: n, less: byFreq}
+	for _, node := range r.Nodes {
+		t.items = append(t.items, node.Top...)
+	}
+	return t.trim()
+}
+
+// ShowTable 通过表格展示所有节点中访问频率最高的 n 个 key
+func (r *HotKeyReport) ShowTable(n int) {
+	var rows []interface{}
+	for _, stat := range r.Top(n) {
+		rows = append(rows, []string{stat.Instance, stat.Key, stat.Type, strconv.FormatInt(stat.Freq, 10)})
+	}
+	headers := []string{"INSTANCE", "KEY", "TYPE", "FREQ"}
+	table.ShowTable(table.GenHeaderCellsByNames(headers), table.GenBodyCells(rows))
+}
+
+// HotKeys 并发分析多个节点的热 key,详见 NodePool.HotKeys
+func HotKeys(addrSlice []string, password string, opts *KeyAnalysisOptions) (*HotKeyReport, error) {
+	return HotKeysContext(context.Background(), addrSlice, password, opts)
+}
+
+// HotKeysContext 并发分析多个节点的热 key, ctx 取消时立即返回
+func HotKeysContext(ctx context.Context, addrSlice []string, password string, opts *KeyAnalysisOptions) (*HotKeyReport, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.HotKeys(ctx, addrSlice, opts)
+}
+
+// HotKeys 通过连接池并发分析多个节点的热 key
+/*
+1.节点的 maxmemory-policy 必须为 allkeys-lfu 或 volatile-lfu, 否则 OBJECT FREQ 不可用, 该节点分析失败
+2.每个节点通过 SCAN 遍历 key,每批 key 通过 pipeline 获取 TYPE 和 OBJECT FREQ,保留访问频率最高的 TopN 个 key
+3.OBJECT FREQ 只反映当前节点上的访问, slave 上的访问频率不包括 master 上的访问
+4.部分节点失败时返回其他节点的结果和失败节点的错误,所有节点都失败时返回错误
+*/
+func (p *NodePool) HotKeys(ctx context.Context, addrSlice []string, opts *KeyAnalysisOptions) (ret *HotKeyReport, err error) {
+	defer logOperation(p.logger(), "HotKeys", "nodes", len(addrSlice))(&err)
+
+	opts = opts.withDefaults()
+	nodes := make([]*NodeHotKeys, len(addrSlice))
+	errs := make([]error, len(addrSlice))
+	eachNode(addrSlice, true, func(i int, addr string) error {
+		nodes[i], errs[i] = p.nodeHotKeys(ctx, addr, opts)
+		return errs[i]
+	})
+
+	ret = &HotKeyReport{Errors: make(map[string]error)}
+	for i, addr := range addrSlice {
+		if errs[i] != nil {
+			ret.Errors[addr] = errs[i]
+			continue
+		}
+		ret.Nodes = append(ret.Nodes, nodes[i])
+	}
+	if len(addrSlice) > 0 && len(ret.Errors) == len(addrSlice) {
+		return nil, errs[0]
+	}
+	sort.Slice(ret.Nodes, func(i, j int) bool { return ret.Nodes[i].Addr < ret.Nodes[j].Addr })
+	return ret, nil
+}
+
+// ClusterHotKeys 并发分析集群所有 master 的热 key
+func ClusterHotKeys(data *ClusterInfo, password string, opts *KeyAnalysisOptions) (*HotKeyReport, error) {
+	return ClusterHotKeysContext(context.Background(), data, password, opts)
+}
+
+// ClusterHotKeysContext 并发分析集群所有 master 的热 key, ctx 取消时立即返回
+func ClusterHotKeysContext(ctx context.Context, data *ClusterInfo, password string, opts *KeyAnalysisOptions) (*HotKeyReport, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterHotKeys(ctx, data, opts)
+}
+
+// ClusterHotKeys 通过连接池并发分析集群所有 master 的热 key
+func (p *NodePool) ClusterHotKeys(ctx context.Context, data *ClusterInfo, opts *KeyAnalysisOptions) (*HotKeyReport, error) {
+	return p.HotKeys(ctx, clusterMasterAddrs(data), opts)
+}
+
+// nodeHotKeys 分析单个节点的热 key
+func (p *NodePool) nodeHotKeys(ctx context.Context, addr string, opts *KeyAnalysisOptions) (*NodeHotKeys, error) {
+	policy, err := p.configGet(ctx, addr, "maxmemory-policy")
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(policy, "-lfu") {
+		return nil, &NodeError{Addr: addr, Op: "OBJECT FREQ (maxmemory-policy " + policy + ")", Err: ErrLFURequired}
+	}
+
+	node := &NodeHotKeys{Addr: addr, Policy: policy}
+	top := &topKeys{n: opts.TopN, less: byFreq}
+	err = p.analyzeKeys(ctx, addr, opts, func(keys []string, pipelined func(queue func(ctx context.Context, pipe redis.Pipeliner)) error) error {
+		var types []*redis.StatusCmd
+		var freqs []*redis.IntCmd
+		err := pipelined(func(ctx context.Context, pipe redis.Pipeliner) {
+			types = queueKeyTypes(ctx, pipe, keys)
+			freqs = make([]*redis.IntCmd, 0, len(keys))
+			for _, key := range keys {
+				cmd := redis.NewIntCmd(ctx, "object", "freq", key)
+				pipe.Process(ctx, cmd)
+				freqs = append(freqs, cmd)
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		for i, key := range keys {
+			if freqs[i].Err() != nil || types[i].Val() == "none" {
+				continue
+			}
+			node.Keys++
+			top.add(&KeyStat{Instance: addr, Key: key, Type: types[i].Val(), Freq: freqs[i].Val()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	node.Top = top.trim()
+	return node, nil
+}
@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTopKeys(t *testing.T) {
+	stats := func(memory ...int64) (ret []*KeyStat) {
+		for _, m := range memory {
+			ret = append(ret, &KeyStat{Key: "k", Memory: m})
+		}
+		return
+	}
+	memories := func(items []*KeyStat) (ret []int64) {
+		for _, item := range items {
+			ret = append(ret, item.Memory)
+		}
+		return
+	}
+	tests := []struct {
+		name  string
+		n     int
+		input []int64
+		want  []int64
+	}{
+		{name: "empty", n: 3, input: nil, want: nil},
+		{name: "fewer than n", n: 3, input: []int64{1, 5}, want: []int64{5, 1}},
+		{name: "trimmed while adding", n: 2, input: []int64{3, 9, 1, 7, 5, 8, 2}, want: []int64{9, 8}},
+		{name: "zero", n: 0, input: []int64{3, 9}, want: nil},
+		{name: "negative", n: -1, input: []int64{3, 9}, want: nil},
+	}
+	for _, tt := range tests {
+		top := &topKeys{n: tt.n, less: byMemory}
+		for _, stat := range stats(tt.input...) {
+			top.add(stat)
+			if tt.n > 0 && len(top.items) > 2*tt.n {
+				t.Errorf("%s: %d items kept while adding, want at most %d", tt.name, len(top.items), 2*tt.n)
+			}
+		}
+		if got := memories(top.trim()); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: trim() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTopKeysStable(t *testing.T) {
+	top := &topKeys{n: 2, less: byLength}
+	for _, key := range []string{"a", "b", "c"} {
+		top.add(&KeyStat{Key: key, Length: 10})
+	}
+	var got []string
+	for _, stat := range top.trim() {
+		got = append(got, stat.Key)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("trim() keys = %v, want %v in insertion order", got, want)
+	}
+}
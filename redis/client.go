@@ -0,0 +1,328 @@
+package redis
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/macoli/gowrapper/table"
+)
+
+// ==================================client list==================================================
+
+// Client CLIENT LIST 返回的单个客户端连接, 低版本 redis 没有的字段为零值
+type Client struct {
+	Instance string        // redis 实例地址
+	ID       int64         // 客户端 ID
+	Addr     string        // 客户端地址 ip:port
+	LAddr    string        // 客户端连接的本地地址 ip:port, redis 6.2 及以上版本才有
+	FD       int64         // 文件描述符, -1 表示内部客户端
+	Name     string        // 客户端名称(CLIENT SETNAME)
+	Age      time.Duration // 连接时长
+	Idle     time.Duration // 空闲时长
+	Flags    string        // 客户端标志, 如 N(普通客户端)、S(slave)、M(master)、P(订阅)、x(MULTI)、b(阻塞)
+	DB       int64         // 当前数据库
+	Sub      int64         // 订阅的频道数量
+	PSub     int64         // 订阅的模式数量
+	Multi    int64         // MULTI 中的命令数量, -1 表示不在 MULTI 中
+	QBuf     int64         // 查询缓冲区长度
+	QBufFree int64         // 查询缓冲区空闲长度
+	ArgvMem  int64         // 下一条命令参数占用的内存
+	OBL      int64         // 输出缓冲区长度
+	OLL      int64         // 输出列表长度
+	OMem     int64         // 输出缓冲区占用的内存
+	TotMem   int64         // 客户端占用的总内存
+	Events   string        // 文件描述符事件 r/w
+	Cmd      string        // 最近执行的命令
+	User     string        // ACL 用户, redis 6 及以上版本才有
+	Raw      map[string]string
+}
+
+// IP 获取客户端的 ip
+func (c *Client) IP() string {
+	host, _, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		return c.Addr
+	}
+	return host
+}
+
+// HasFlag 判断客户端是否有标志 flag
+func (c *Client) HasFlag(flag byte) bool {
+	return strings.IndexByte(c.Flags, flag) >= 0
+}
+
+// ParseClientList 解析 CLIENT LIST 返回的字符串,每行一个客户端: id=3 addr=127.0.0.1:50188 ... cmd=client|list user=default
+func ParseClientList(s string) ([]*Client, error) {
+	var clients []*Client
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		c := &Client{Raw: make(map[string]string)}
+		for _, field := range strings.Fields(line) {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return nil, &ParseError{Kind: "CLIENT LIST", Input: line, Err: ErrInvalidFormat}
+			}
+			c.Raw[kv[0]] = kv[1]
+		}
+		if err := c.parseFields(); err != nil {
+			return nil, &ParseError{Kind: "CLIENT LIST", Input: line, Err: err}
+		}
+		clients = append(clients, c)
+	}
+	return clients, nil
+}
+
+// parseFields 根据 Raw 填充客户端的字段
+func (c *Client) parseFields() error {
+	ints := map[string]*int64{
+		"id": &c.ID, "fd": &c.FD, "db": &c.DB, "sub": &c.Sub, "psub": &c.PSub, "multi": &c.Multi,
+		"qbuf": &c.QBuf, "qbuf-free": &c.QBufFree, "argv-mem": &c.ArgvMem, "obl": &c.OBL, "oll": &c.OLL,
+		"omem": &c.OMem, "tot-mem": &c.TotMem,
+	}
+	for key, ptr := range ints {
+		value, ok := c.Raw[key]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*ptr = n
+	}
+	for key, ptr := range map[string]*time.Duration{"age": &c.Age, "idle": &c.Idle} {
+		value, ok := c.Raw[key]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*ptr = time.Duration(n) * time.Second
+	}
+	c.Addr = c.Raw["addr"]
+	c.LAddr = c.Raw["laddr"]
+	c.Name = c.Raw["name"]
+	c.Flags = c.Raw["flags"]
+	c.Events = c.Raw["events"]
+	c.Cmd = c.Raw["cmd"]
+	c.User = c.Raw["user"]
+	return nil
+}
+
+// ClientListFormat 获取 redis 的客户端连接并格式化
+func ClientListFormat(addr, password string) ([]*Client, error) {
+	return ClientListFormatContext(context.Background(), addr, password)
+}
+
+// ClientListFormatContext 获取 redis 的客户端连接并格式化, ctx 取消时立即返回
+func ClientListFormatContext(ctx context.Context, addr, password string) ([]*Client, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClientList(ctx, addr)
+}
+
+// ClientList 通过连接池获取 redis 的客户端连接并格式化, 结果包括本次获取使用的连接
+func (p *NodePool) ClientList(ctx context.Context, addr string) ([]*Client, error) {
+	rc, release, err := p.acquire(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var ret string
+	err = p.retry(ctx, addr, "CLIENT LIST", func() (err error) {
+		ret, err = rc.ClientList(ctx).Result()
+		return
+	})
+	if err != nil {
+		return nil, &NodeError{Addr: addr, Op: "CLIENT LIST", Err: err}
+	}
+
+	clients, err := ParseClientList(ret)
+	if err != nil {
+		if parseErr, ok := err.(*ParseError); ok {
+			parseErr.Addr = addr
+		}
+		return nil, err
+	}
+	for _, c := range clients {
+		c.Instance = addr
+	}
+	return clients, nil
+}
+
+// ==================================cluster client list==================================================
+
+// ClusterClientResult 多个节点的客户端连接
+type ClusterClientResult struct {
+	Clients []*Client        // 所有节点的客户端连接
+	Errors  map[string]error // 获取失败的节点 {addr: err}
+}
+
+// ClientIPStat 单个来源 ip 的客户端连接统计
+type ClientIPStat struct {
+	IP          string
+	Connections int            // 连接总数
+	Nodes       map[string]int // 每个节点上的连接数 {addr: count}
+	Names       []string       // 客户端名称, 去重后排序
+	Users       []string       // ACL 用户, 去重后排序
+	TotMem      int64          // 所有连接占用的总内存
+	MaxIdle     time.Duration  // 最长的空闲时长
+}
+
+// ClientsByIP 按来源 ip 汇总客户端连接,结果按连接数倒序排列, 不包括 slave、master 和内部客户端的连接
+func ClientsByIP(clients []*Client) []*ClientIPStat {
+	stats := make(map[string]*ClientIPStat)
+	names := make(map[string]map[string]bool)
+	users := make(map[string]map[string]bool)
+	for _, c := range clients {
+		if c.HasFlag('S') || c.HasFlag('M') || c.FD < 0 {
+			continue
+		}
+		ip := c.IP()
+		stat, ok := stats[ip]
+		if !ok {
+			stat = &ClientIPStat{IP: ip, Nodes: make(map[string]int)}
+			stats[ip] = stat
+			names[ip] = make(map[string]bool)
+			users[ip] = make(map[string]bool)
+		}
+		stat.Connections++
+		stat.Nodes[c.Instance]++
+		stat.TotMem += c.TotMem
+		if c.Idle > stat.MaxIdle {
+			stat.MaxIdle = c.Idle
+		}
+		if c.Name != "" {
+			names[ip][c.Name] = true
+		}
+		if c.User != "" {
+			users[ip][c.User] = true
+		}
+	}
+
+	ret := make([]*ClientIPStat, 0, len(stats))
+	for ip, stat := range stats {
+		stat.Names = sortedKeys(names[ip])
+		stat.Users = sortedKeys(users[ip])
+		ret = append(ret, stat)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Connections != ret[j].Connections {
+			return ret[i].Connections > ret[j].Connections
+		}
+		return ret[i].IP < ret[j].IP
+	})
+	return ret
+}
+
+// sortedKeys 获取 map 排序后的 key
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ByIP 按来源 ip 汇总所有节点的客户端连接,详见 ClientsByIP
+func (r *ClusterClientResult) ByIP() []*ClientIPStat {
+	return ClientsByIP(r.Clients)
+}
+
+// ShowTable 通过表格展示按来源 ip 汇总的客户端连接
+func (r *ClusterClientResult) ShowTable() {
+	var rows []interface{}
+	for _, stat := range r.ByIP() {
+		nodes := make(map[string]string, len(stat.Nodes))
+		for addr, count := range stat.Nodes {
+			nodes[addr] = strconv.Itoa(count)
+		}
+		rows = append(rows, []string{
+			stat.IP,
+			strconv.Itoa(stat.Connections),
+			strings.ReplaceAll(formatNodeValues(nodes), `"`, ""),
+			strings.Join(stat.Names, ","),
+			strings.Join(stat.Users, ","),
+			strconv.FormatInt(stat.TotMem, 10),
+			stat.MaxIdle.String(),
+		})
+	}
+	headers := []string{"IP", "CONNECTIONS", "NODES", "NAMES", "USERS", "TOT-MEM", "MAX-IDLE"}
+	table.ShowTable(table.GenHeaderCellsByNames(headers), table.GenBodyCells(rows))
+}
+
+// ClusterClientList 并发获取集群所有 master 和 slave 的客户端连接
+func ClusterClientList(data *ClusterInfo, password string) (*ClusterClientResult, error) {
+	return ClusterClientListContext(context.Background(), data, password)
+}
+
+// ClusterClientListContext 并发获取集群所有 master 和 slave 的客户端连接, ctx 取消时立即返回
+func ClusterClientListContext(ctx context.Context, data *ClusterInfo, password string) (*ClusterClientResult, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterClientList(ctx, data)
+}
+
+// ClusterClientList 通过连接池并发获取集群所有 master 和 slave 的客户端连接,
+// 部分节点获取失败时返回其他节点的结果和失败节点的错误,所有节点都失败时返回错误
+func (p *NodePool) ClusterClientList(ctx context.Context, data *ClusterInfo) (*ClusterClientResult, error) {
+	return p.clientLists(ctx, clusterGroupNodes(data))
+}
+
+// SentinelClientList 通过哨兵获取 master name 对应的 master 和所有 slave 的客户端连接
+func SentinelClientList(ctx context.Context, sentinelAddrs []string, masterName string, opts *ConnOptions) (*ClusterClientResult, error) {
+	p := NewNodePool(opts)
+	defer p.Close()
+	return p.SentinelClientList(ctx, sentinelAddrs, masterName)
+}
+
+// SentinelClientList 通过哨兵获取 master name 对应的 master 和所有 slave,并发获取它们的客户端连接
+func (p *NodePool) SentinelClientList(ctx context.Context, sentinelAddrs []string, masterName string) (*ClusterClientResult, error) {
+	nodes, err := p.sentinelGroupNodes(ctx, sentinelAddrs, masterName)
+	if err != nil {
+		return nil, err
+	}
+	return p.clientLists(ctx, nodes)
+}
+
+// clientLists 并发获取节点的客户端连接
+func (p *NodePool) clientLists(ctx context.Context, nodes []groupNode) (ret *ClusterClientResult, err error) {
+	defer logOperation(p.logger(), "ClusterClientList", "nodes", len(nodes))(&err)
+
+	addrs := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		addrs = append(addrs, node.addr)
+	}
+	clients := make([][]*Client, len(nodes))
+	errs := make([]error, len(nodes))
+	eachNode(addrs, true, func(i int, addr string) error {
+		clients[i], errs[i] = p.ClientList(ctx, addr)
+		return errs[i]
+	})
+
+	ret = &ClusterClientResult{Errors: make(map[string]error)}
+	for i, addr := range addrs {
+		if errs[i] != nil {
+			ret.Errors[addr] = errs[i]
+			continue
+		}
+		ret.Clients = append(ret.Clients, clients[i]...)
+	}
+	if len(nodes) > 0 && len(ret.Errors) == len(nodes) {
+		return nil, errs[0]
+	}
+	return ret, nil
+}
@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseClientList(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []*Client
+		err   error
+	}{
+		{name: "empty", input: "", want: nil},
+		{
+			name: "redis 7",
+			input: "id=3 addr=10.0.0.9:50188 laddr=10.0.0.1:6379 fd=8 name=api age=120 idle=5 flags=N db=0 sub=0 psub=0 " +
+				"multi=-1 qbuf=26 qbuf-free=20448 argv-mem=10 obl=0 oll=0 omem=0 tot-mem=61466 events=r cmd=client|list user=default\n",
+			want: []*Client{{
+				ID: 3, Addr: "10.0.0.9:50188", LAddr: "10.0.0.1:6379", FD: 8, Name: "api", Age: 120 * time.Second, Idle: 5 * time.Second,
+				Flags: "N", Multi: -1, QBuf: 26, QBufFree: 20448, ArgvMem: 10, TotMem: 61466, Events: "r", Cmd: "client|list", User: "default",
+			}},
+		},
+		{
+			name:  "redis 3 without user and empty name",
+			input: "id=5 addr=10.0.0.8:6380 fd=-1 name= age=3 idle=0 flags=S db=1 cmd=replconf\r\n\r\n",
+			want:  []*Client{{ID: 5, Addr: "10.0.0.8:6380", FD: -1, Age: 3 * time.Second, Flags: "S", DB: 1, Cmd: "replconf"}},
+		},
+		{name: "field without value", input: "id=3 addr", err: ErrInvalidFormat},
+		{name: "bad number", input: "id=x addr=10.0.0.9:50188", err: strconv.ErrSyntax},
+		{name: "bad duration", input: "id=1 idle=x", err: strconv.ErrSyntax},
+	}
+	for _, tt := range tests {
+		got, err := ParseClientList(tt.input)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Kind != "CLIENT LIST" {
+				t.Errorf("%s: err = %v, want CLIENT LIST ParseError", tt.name, err)
+			}
+			continue
+		}
+		for _, c := range got {
+			c.Raw = nil
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseClientList() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClientsByIP(t *testing.T) {
+	clients := []*Client{
+		{Instance: "a:6379", Addr: "10.0.0.1:1001", Name: "api", User: "default", TotMem: 100, Idle: 5 * time.Second, Flags: "N"},
+		{Instance: "b:6379", Addr: "10.0.0.1:1002", Name: "api", User: "app", TotMem: 200, Idle: time.Second, Flags: "N"},
+		{Instance: "a:6379", Addr: "10.0.0.1:1003", TotMem: 50, Flags: "P"},
+		{Instance: "a:6379", Addr: "10.0.0.2:1001", Name: "job", TotMem: 10, Idle: time.Minute, Flags: "N"},
+		{Instance: "a:6379", Addr: "[::1]:1001", TotMem: 10, Flags: "N"},
+		{Instance: "a:6379", Addr: "10.0.0.3:6379", Flags: "S"},
+		{Instance: "b:6379", Addr: "10.0.0.4:6379", Flags: "M"},
+		{Instance: "a:6379", Addr: "", FD: -1, Flags: "N"},
+	}
+	want := []*ClientIPStat{
+		{IP: "10.0.0.1", Connections: 3, Nodes: map[string]int{"a:6379": 2, "b:6379": 1},
+			Names: []string{"api"}, Users: []string{"app", "default"}, TotMem: 350, MaxIdle: 5 * time.Second},
+		{IP: "10.0.0.2", Connections: 1, Nodes: map[string]int{"a:6379": 1},
+			Names: []string{"job"}, Users: []string{}, TotMem: 10, MaxIdle: time.Minute},
+		{IP: "::1", Connections: 1, Nodes: map[string]int{"a:6379": 1},
+			Names: []string{}, Users: []string{}, TotMem: 10},
+	}
+	if got := ClientsByIP(clients); !reflect.DeepEqual(got, want) {
+		for i := range got {
+			t.Logf("got[%d] = %+v", i, got[i])
+		}
+		t.Errorf("ClientsByIP() differs from want")
+	}
+}
@@ -0,0 +1,275 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/macoli/gowrapper/table"
+)
+
+// ==================================client check and kill==================================================
+
+// 客户端连接的问题类型
+const (
+	ClientIssueIdle    = "idle"    // 空闲时间超过阈值
+	ClientIssueOMem    = "omem"    // 输出缓冲区占用的内存超过阈值
+	ClientIssueBlocked = "blocked" // 阻塞在 BLPOP、XREAD 等命令上
+	ClientIssueMonitor = "monitor" // 正在执行 MONITOR
+)
+
+// ClientCheckOptions 客户端连接检查选项, 为 0 或 false 的项不检查
+type ClientCheckOptions struct {
+	MaxIdle time.Duration // 空闲时间阈值, 订阅客户端(P 标志)不参与空闲检查
+	MaxOMem int64         // 输出缓冲区内存阈值(字节)
+	Blocked bool          // 是否报告阻塞的客户端
+	Monitor bool          // 是否报告执行 MONITOR 的客户端
+}
+
+// ClientIssue 有问题的客户端连接
+type ClientIssue struct {
+	Client *Client
+	Kinds  []string // 问题类型
+}
+
+// ClientReport 客户端连接检查报告
+type ClientReport struct {
+	Issues []*ClientIssue   // 按节点地址、客户端 ID 排序
+	Errors map[string]error // 获取客户端连接失败的节点 {addr: err}
+}
+
+// CheckClients 检查客户端连接, slave、master 的复制连接和内部客户端不参与检查
+func CheckClients(clients []*Client, opts *ClientCheckOptions) []*ClientIssue {
+	if opts == nil {
+		opts = &ClientCheckOptions{}
+	}
+	var issues []*ClientIssue
+	for _, c := range clients {
+		if c.HasFlag('S') || c.HasFlag('M') || c.FD < 0 {
+			continue
+		}
+		var kinds []string
+		if opts.MaxIdle > 0 && c.Idle > opts.MaxIdle && !c.HasFlag('P') {
+			kinds = append(kinds, ClientIssueIdle)
+		}
+		if opts.MaxOMem > 0 && c.OMem > opts.MaxOMem {
+			kinds = append(kinds, ClientIssueOMem)
+		}
+		if opts.Blocked && c.HasFlag('b') {
+			kinds = append(kinds, ClientIssueBlocked)
+		}
+		if opts.Monitor && c.HasFlag('O') {
+			kinds = append(kinds, ClientIssueMonitor)
+		}
+		if len(kinds) > 0 {
+			issues = append(issues, &ClientIssue{Client: c, Kinds: kinds})
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i].Client, issues[j].Client
+		if a.Instance != b.Instance {
+			return a.Instance < b.Instance
+		}
+		return a.ID < b.ID
+	})
+	return issues
+}
+
+// Check 检查所有节点的客户端连接,详见 CheckClients
+func (r *ClusterClientResult) Check(opts *ClientCheckOptions) *ClientReport {
+	return &ClientReport{Issues: CheckClients(r.Clients, opts), Errors: r.Errors}
+}
+
+// Clients 获取所有有问题的客户端连接,可用于 ClientKill
+func (r *ClientReport) Clients() []*Client {
+	clients := make([]*Client, 0, len(r.Issues))
+	for _, issue := range r.Issues {
+		clients = append(clients, issue.Client)
+	}
+	return clients
+}
+
+// ShowTable 通过表格展示有问题的客户端连接
+func (r *ClientReport) ShowTable() {
+	var rows []interface{}
+	for _, issue := range r.Issues {
+		c := issue.Client
+		rows = append(rows, []string{
+			c.Instance,
+			strconv.FormatInt(c.ID, 10),
+			c.Addr,
+			c.Name,
+			c.Flags,
+			c.Idle.String(),
+			strconv.FormatInt(c.OMem, 10),
+			c.Cmd,
+			strings.Join(issue.Kinds, ","),
+		})
+	}
+	headers := []string{"INSTANCE", "CLIENT-ID", "ADDR", "NAME", "FLAGS", "IDLE", "OMEM", "CMD", "ISSUES"}
+	table.ShowTable(table.GenHeaderCellsByNames(headers), table.GenBodyCells(rows))
+}
+
+// verifyClientKill 校验断开客户端连接的确认令牌, 令牌根据目标节点的 run_id 计算
+func (p *NodePool) verifyClientKill(ctx context.Context, addrSlice []string, g *Guard) error {
+	if g == nil {
+		return nil
+	}
+	ids, err := p.runIDs(ctx, addrSlice)
+	if err != nil {
+		return err
+	}
+	return g.verify(ids)
+}
+
+// clientKillTimeout 每条 CLIENT KILL 命令的超时时间
+const clientKillTimeout = 5 * time.Second
+
+// killClient 在节点上执行一条 CLIENT KILL ID, 超时时间为 clientKillTimeout
+func (p *NodePool) killClient(ctx context.Context, g *Guard, rc doer, addr string, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, clientKillTimeout)
+	defer cancel()
+	return p.do(ctx, g, rc, addr, "client", "kill", "id", id)
+}
+
+// isNoSuchClient 判断错误是否为客户端连接已经不存在
+func isNoSuchClient(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "no such client")
+}
+
+// ClientKill 通过 CLIENT KILL ID 断开客户端连接,详见 NodePool.ClientKill
+func ClientKill(clients []*Client, password string, g *Guard) error {
+	return ClientKillContext(context.Background(), clients, password, g)
+}
+
+// ClientKillContext 通过 CLIENT KILL ID 断开客户端连接, ctx 取消时立即返回
+func ClientKillContext(ctx context.Context, clients []*Client, password string, g *Guard) error {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClientKill(ctx, clients, g)
+}
+
+// ClientKill 通过连接池在客户端所在节点(Client.Instance)上执行 CLIENT KILL ID 断开客户端连接
+/*
+1.g 不为 nil 时,确认令牌根据所有目标节点的 run_id 计算,可先通过演练模式获取令牌和将要执行的命令
+2.并发处理每个节点,同一节点上的连接按顺序断开,每条命令单独计算超时时间,连接已经不存在时忽略
+3.任意节点失败时返回第一个错误,其他节点继续执行
+*/
+func (p *NodePool) ClientKill(ctx context.Context, clients []*Client, g *Guard) (err error) {
+	defer logOperation(p.logger(), "ClientKill", "clients", len(clients))(&err)
+
+	byAddr := make(map[string][]*Client)
+	var addrs []string
+	for i, c := range clients {
+		if c.Instance == "" {
+			return &ArgError{Name: fmt.Sprintf("clients[%d].Instance", i), Value: c.Instance, Err: ErrNoInstance}
+		}
+		if _, ok := byAddr[c.Instance]; !ok {
+			addrs = append(addrs, c.Instance)
+		}
+		byAddr[c.Instance] = append(byAddr[c.Instance], c)
+	}
+	sort.Strings(addrs)
+
+	if err = p.verifyClientKill(ctx, addrs, g); err != nil {
+		return err
+	}
+
+	_, err = eachNode(addrs, true, func(i int, addr string) error {
+		rc, release, err := p.acquire(ctx, addr)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		for _, c := range byAddr[addr] {
+			err = p.killClient(ctx, g, rc, addr, c.ID)
+			if err != nil && !isNoSuchClient(err) {
+				return &NodeError{Addr: addr, Op: fmt.Sprintf("CLIENT KILL ID %d", c.ID), Err: err}
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// ClientKillFilter CLIENT KILL 的过滤条件, 为空的条件不使用, 至少需要一个条件
+type ClientKillFilter struct {
+	Type   string        // 客户端类型: normal、master、replica、pubsub
+	User   string        // ACL 用户, redis 6 及以上版本才支持
+	Addr   string        // 客户端地址 ip:port
+	LAddr  string        // 客户端连接的本地地址 ip:port, redis 6.2 及以上版本才支持
+	MaxAge time.Duration // 连接时长超过 MaxAge, redis 7.4 及以上版本才支持
+}
+
+// args 生成 CLIENT KILL 命令参数, 总是带上 SKIPME yes, 不会断开执行命令的连接
+func (f *ClientKillFilter) args() ([]interface{}, error) {
+	if f == nil {
+		return nil, ErrFilterRequired
+	}
+	args := []interface{}{"client", "kill"}
+	if f.Type != "" {
+		args = append(args, "type", f.Type)
+	}
+	if f.User != "" {
+		args = append(args, "user", f.User)
+	}
+	if f.Addr != "" {
+		args = append(args, "addr", f.Addr)
+	}
+	if f.LAddr != "" {
+		args = append(args, "laddr", f.LAddr)
+	}
+	if f.MaxAge > 0 {
+		args = append(args, "maxage", int64(f.MaxAge/time.Second))
+	}
+	if len(args) == 2 {
+		return nil, ErrFilterRequired
+	}
+	return append(args, "skipme", "yes"), nil
+}
+
+// ClientKillByFilter 在多个节点上按过滤条件断开客户端连接,详见 NodePool.ClientKillByFilter
+func ClientKillByFilter(addrSlice []string, password string, filter *ClientKillFilter, g *Guard) error {
+	return ClientKillByFilterContext(context.Background(), addrSlice, password, filter, g)
+}
+
+// ClientKillByFilterContext 在多个节点上按过滤条件断开客户端连接, ctx 取消时立即返回
+func ClientKillByFilterContext(ctx context.Context, addrSlice []string, password string, filter *ClientKillFilter, g *Guard) error {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClientKillByFilter(ctx, addrSlice, filter, g)
+}
+
+// ClientKillByFilter 通过连接池在所有节点上并发执行 CLIENT KILL <filter> SKIPME yes,
+// g 不为 nil 时,确认令牌根据所有节点的 run_id 计算,任意节点失败时返回第一个错误,其他节点继续执行
+func (p *NodePool) ClientKillByFilter(ctx context.Context, addrSlice []string, filter *ClientKillFilter, g *Guard) (err error) {
+	defer logOperation(p.logger(), "ClientKillByFilter", "nodes", len(addrSlice))(&err)
+
+	args, err := filter.args()
+	if err != nil {
+		return err
+	}
+	if err = p.verifyClientKill(ctx, addrSlice, g); err != nil {
+		return err
+	}
+
+	_, err = eachNode(addrSlice, true, func(i int, addr string) error {
+		rc, release, err := p.acquire(ctx, addr)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		ctx, cancel := context.WithTimeout(ctx, clientKillTimeout)
+		defer cancel()
+		if err = p.do(ctx, g, rc, addr, args...); err != nil {
+			return &NodeError{Addr: addr, Op: strings.ToUpper(formatArgs(args)), Err: err}
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,108 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheckClients(t *testing.T) {
+	clients := []*Client{
+		{Instance: "b:6379", ID: 1, Flags: "N", Idle: time.Hour},
+		{Instance: "a:6379", ID: 9, Flags: "N", Idle: time.Hour, OMem: 2 << 20},
+		{Instance: "a:6379", ID: 2, Flags: "P", Idle: time.Hour},
+		{Instance: "a:6379", ID: 3, Flags: "b", Cmd: "blpop"},
+		{Instance: "a:6379", ID: 4, Flags: "O", Cmd: "monitor"},
+		{Instance: "a:6379", ID: 5, Flags: "N", Idle: time.Second, OMem: 100},
+		{Instance: "a:6379", ID: 6, Flags: "S", Idle: time.Hour, OMem: 2 << 20},
+		{Instance: "a:6379", ID: 7, Flags: "M", Idle: time.Hour},
+		{Instance: "a:6379", ID: 8, FD: -1, Flags: "N", Idle: time.Hour},
+	}
+	ids := func(issues []*ClientIssue) (ret [][2]interface{}) {
+		for _, issue := range issues {
+			ret = append(ret, [2]interface{}{issue.Client.Instance, issue.Client.ID})
+		}
+		return
+	}
+	tests := []struct {
+		name  string
+		opts  *ClientCheckOptions
+		ids   [][2]interface{}
+		kinds [][]string
+	}{
+		{name: "nil options", opts: nil},
+		{
+			name:  "idle",
+			opts:  &ClientCheckOptions{MaxIdle: time.Minute},
+			ids:   [][2]interface{}{{"a:6379", int64(9)}, {"b:6379", int64(1)}},
+			kinds: [][]string{{ClientIssueIdle}, {ClientIssueIdle}},
+		},
+		{
+			name:  "omem",
+			opts:  &ClientCheckOptions{MaxOMem: 1 << 20},
+			ids:   [][2]interface{}{{"a:6379", int64(9)}},
+			kinds: [][]string{{ClientIssueOMem}},
+		},
+		{
+			name: "all",
+			opts: &ClientCheckOptions{MaxIdle: time.Minute, MaxOMem: 1 << 20, Blocked: true, Monitor: true},
+			ids: [][2]interface{}{
+				{"a:6379", int64(3)}, {"a:6379", int64(4)}, {"a:6379", int64(9)}, {"b:6379", int64(1)},
+			},
+			kinds: [][]string{
+				{ClientIssueBlocked}, {ClientIssueMonitor}, {ClientIssueIdle, ClientIssueOMem}, {ClientIssueIdle},
+			},
+		},
+	}
+	for _, tt := range tests {
+		issues := CheckClients(clients, tt.opts)
+		if got := ids(issues); !reflect.DeepEqual(got, tt.ids) {
+			t.Errorf("%s: CheckClients() = %v, want %v", tt.name, got, tt.ids)
+			continue
+		}
+		for i, issue := range issues {
+			if !reflect.DeepEqual(issue.Kinds, tt.kinds[i]) {
+				t.Errorf("%s: client %d kinds = %v, want %v", tt.name, issue.Client.ID, issue.Kinds, tt.kinds[i])
+			}
+		}
+	}
+}
+
+func TestClientReportClients(t *testing.T) {
+	a, b := &Client{ID: 1}, &Client{ID: 2}
+	r := &ClientReport{Issues: []*ClientIssue{{Client: a}, {Client: b}}}
+	if got := r.Clients(); !reflect.DeepEqual(got, []*Client{a, b}) {
+		t.Errorf("Clients() = %v, want [%v %v]", got, a, b)
+	}
+}
+
+func TestClientKillSkipsGoneClients(t *testing.T) {
+	var mu sync.Mutex
+	var killed []string
+	addr := fakeRedis(t, func(args []string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.ToLower(args[0]) != "client" || strings.ToLower(args[2]) != "id" {
+			return errors.New("ERR unknown command '" + args[0] + "'")
+		}
+		if args[3] == "2" {
+			return errors.New("ERR No such client")
+		}
+		killed = append(killed, args[3])
+		return "OK"
+	})
+
+	clients := []*Client{{Instance: addr, ID: 1}, {Instance: addr, ID: 2}, {Instance: addr, ID: 3}}
+	if err := ClientKillContext(context.Background(), clients, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"1", "3"}; !reflect.DeepEqual(killed, want) {
+		t.Errorf("killed %v, want %v", killed, want)
+	}
+}
@@ -2,12 +2,13 @@ package redis
 
 import (
 	"context"
-	"errors"
-	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/macoli/gowrapper/slice"
 )
 
@@ -46,18 +47,15 @@ func getNodes(nodesStr string) (nodes []*ClusterNode, err error) {
 		node.MasterID = fields[3]
 		node.PingSent, err = strconv.ParseInt(fields[4], 10, 64)
 		if err != nil {
-			errMsg := fmt.Sprintf("%s 的 ping-sent 字段 %s 转换成 int64 类型失败, err:%v\n", node.Addr, fields[4], err)
-			return nil, errors.New(errMsg)
+			return nil, &ParseError{Addr: node.Addr, Kind: "cluster nodes ping-sent", Input: fields[4], Err: err}
 		}
 		node.PongRecv, err = strconv.ParseInt(fields[5], 10, 64)
 		if err != nil {
-			errMsg := fmt.Sprintf("%s 的 pong-recv 字段 %s 转换成 int64 类型失败, err:%v\n", node.Addr, fields[4], err)
-			return nil, errors.New(errMsg)
+			return nil, &ParseError{Addr: node.Addr, Kind: "cluster nodes pong-recv", Input: fields[5], Err: err}
 		}
 		node.ConfigEpoch, err = strconv.ParseInt(fields[6], 10, 64)
 		if err != nil {
-			errMsg := fmt.Sprintf("%s 的 config-epoch 字段 %s 转换成 int64 类型失败 err:%v\n", node.Addr, fields[4], err)
-			return nil, errors.New(errMsg)
+			return nil, &ParseError{Addr: node.Addr, Kind: "cluster nodes config-epoch", Input: fields[6], Err: err}
 		}
 		node.LinkState = fields[7]
 		if len(fields) == 8 {
@@ -168,179 +166,555 @@ func ClusterInfoFormat(nodeStr string) (data *ClusterInfo, err error) {
 
 // =================================cluster config=================================================
 
-// ClusterConfigCheck 校验集群配置项是否一致
-func ClusterConfigCheck(addrSlice []string, password, configArg string) (bool, error) {
-	var retValue string
-	for _, addr := range addrSlice {
-		// 创建 redis 连接
-		rc, err := InitStandConn(addr, password)
-		if err != nil {
-			return false, err
+// configGet 获取单个节点的配置项, 配置项名称不区分大小写
+func (p *NodePool) configGet(ctx context.Context, addr, configKey string) (string, error) {
+	ret, err := p.configGetAll(ctx, addr, configKey)
+	if err != nil {
+		return "", err
+	}
+	if value, ok := ret[strings.ToLower(configKey)]; ok {
+		return value, nil
+	}
+	// 只返回一个配置项时直接使用该配置项的值
+	if len(ret) == 1 {
+		for _, value := range ret {
+			return value, nil
 		}
+	}
+	return "", &NodeError{Addr: addr, Op: "CONFIG GET " + configKey, Err: ErrConfigUnsupported}
+}
+
+// configGetAll 获取单个节点上所有匹配 pattern 的配置项 {key: value}
+func (p *NodePool) configGetAll(ctx context.Context, addr, pattern string) (map[string]string, error) {
+	// 连接 redis
+	rc, release, err := p.acquire(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
-		// 获取配置项
-		argRet, err := rc.ConfigGet(ctx, configArg).Result()
-		if err != nil {
-			return false, err
-		}
+	var argRet []interface{}
+	err = p.retry(ctx, addr, "CONFIG GET", func() (err error) {
+		argRet, err = rc.ConfigGet(ctx, pattern).Result()
+		return
+	})
+	if err != nil {
+		return nil, &NodeError{Addr: addr, Op: "CONFIG GET " + pattern, Err: err}
+	}
 
-		if retValue != argRet[1].(string) && retValue != "" {
-			err = errors.New("集群配置项的值不一致")
-			return false, err
-		} else {
-			retValue = argRet[1].(string)
-		}
+	// CONFIG GET 返回 key、value 交替排列的数组
+	ret := make(map[string]string, len(argRet)/2)
+	for i := 0; i+1 < len(argRet); i += 2 {
+		key, _ := argRet[i].(string)
+		value, _ := argRet[i+1].(string)
+		ret[key] = value
+	}
+	return ret, nil
+}
+
+// ClusterConfigCheck 校验集群配置项是否一致
+func ClusterConfigCheck(addrSlice []string, password, configArg string) (bool, error) {
+	return ClusterConfigCheckContext(context.Background(), addrSlice, password, configArg)
+}
+
+// ClusterConfigCheckContext 校验集群配置项是否一致, ctx 取消时立即返回
+func ClusterConfigCheckContext(ctx context.Context, addrSlice []string, password, configArg string) (bool, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterConfigCheck(ctx, addrSlice, configArg)
+}
 
-		cancel()
-		rc.Close()
+// ClusterConfigCheck 通过连接池校验集群配置项是否一致
+func (p *NodePool) ClusterConfigCheck(ctx context.Context, addrSlice []string, configArg string) (bool, error) {
+	if _, err := p.ClusterConfigGet(ctx, addrSlice, configArg); err != nil {
+		return false, err
 	}
 	return true, nil
 }
 
 // ClusterConfigGet 获取集群配置并校验是否一致
 func ClusterConfigGet(addrSlice []string, password, configKey string) (ret string, err error) {
+	return ClusterConfigGetContext(context.Background(), addrSlice, password, configKey)
+}
+
+// ClusterConfigGetContext 获取集群配置并校验是否一致, ctx 的截止时间同时作用于连接建立
+func ClusterConfigGetContext(ctx context.Context, addrSlice []string, password, configKey string) (ret string, err error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterConfigGet(ctx, addrSlice, configKey)
+}
+
+// ClusterConfigGet 通过连接池获取集群配置并校验是否一致
+func (p *NodePool) ClusterConfigGet(ctx context.Context, addrSlice []string, configKey string) (ret string, err error) {
+	defer logOperation(p.logger(), "ClusterConfigGet", "nodes", len(addrSlice), "key", configKey)(&err)
+
+	values := make(map[string]string, len(addrSlice))
 	for _, addr := range addrSlice {
-		// 连接 redis
-		rc, err := InitStandConn(addr, password)
+		retValue, err := p.configGet(ctx, addr, configKey)
 		if err != nil {
 			return "", err
 		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		argRet, err := rc.ConfigGet(ctx, configKey).Result()
-		if err != nil {
-			errMsg := fmt.Sprintf("获取集群配置项 %s 失败, err:%v\n", configKey, err)
-			return "", errors.New(errMsg)
-		}
-		retValue := argRet[1].(string)
-		if ret != argRet[1].(string) && ret != "" {
-			err := errors.New("集群配置项的值不一致")
-			return "", err
+		values[addr] = retValue
+		if ret != "" && !ConfigValueEqual(configKey, ret, retValue) {
+			return "", &ConfigMismatchError{Key: configKey, Values: values}
 		} else {
 			ret = retValue
 		}
-
-		cancel()
-		rc.Close()
 	}
 	return
 }
 
+// ClusterConfigGetPattern 并发获取集群每个节点上所有匹配 pattern(支持通配符)的配置项 {addr: {key: value}}
+func ClusterConfigGetPattern(addrSlice []string, password, pattern string) (map[string]map[string]string, error) {
+	return ClusterConfigGetPatternContext(context.Background(), addrSlice, password, pattern)
+}
+
+// ClusterConfigGetPatternContext 并发获取集群每个节点上所有匹配 pattern 的配置项, ctx 取消时立即返回
+func ClusterConfigGetPatternContext(ctx context.Context, addrSlice []string, password, pattern string) (map[string]map[string]string, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterConfigGetPattern(ctx, addrSlice, pattern)
+}
+
+// ClusterConfigGetPattern 通过连接池并发获取集群每个节点上所有匹配 pattern(支持通配符)的配置项 {addr: {key: value}}
+func (p *NodePool) ClusterConfigGetPattern(ctx context.Context, addrSlice []string, pattern string) (_ map[string]map[string]string, err error) {
+	defer logOperation(p.logger(), "ClusterConfigGetPattern", "nodes", len(addrSlice), "pattern", pattern)(&err)
+
+	configs := make([]map[string]string, len(addrSlice))
+	_, err = eachNode(addrSlice, true, func(i int, addr string) (err error) {
+		configs[i], err = p.configGetAll(ctx, addr, pattern)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]map[string]string, len(addrSlice))
+	for i, addr := range addrSlice {
+		ret[addr] = configs[i]
+	}
+	return ret, nil
+}
+
+// ConfigSetOptions 批量设置集群配置的选项
+type ConfigSetOptions struct {
+	Parallel bool   // 并发设置所有节点,默认按顺序逐个设置
+	Rewrite  bool   // 设置成功后在每个节点执行 CONFIG REWRITE,使配置在重启后依然生效
+	Guard    *Guard // 危险操作防护,为 nil 时直接执行
+}
+
 // ClusterConfigSet 批量设置集群配置
 func ClusterConfigSet(addrSlice []string, password, configKey, setValue string) (err error) {
-	// 校验集群配置是否一致
-	_, err = ClusterConfigGet(addrSlice, password, configKey)
+	return ClusterConfigSetContext(context.Background(), addrSlice, password, configKey, setValue)
+}
+
+// ClusterConfigSetContext 批量设置集群配置, ctx 取消时停止设置并回滚已修改的节点
+func ClusterConfigSetContext(ctx context.Context, addrSlice []string, password, configKey, setValue string) (err error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterConfigSet(ctx, addrSlice, configKey, setValue, nil)
+}
+
+// ClusterConfigSetWithGuard 批量设置集群配置,通过 Guard 支持演练模式、确认令牌和审计日志
+func ClusterConfigSetWithGuard(addrSlice []string, password, configKey, setValue string, g *Guard) (err error) {
+	return ClusterConfigSetWithGuardContext(context.Background(), addrSlice, password, configKey, setValue, g)
+}
+
+// ClusterConfigSetWithGuardContext 批量设置集群配置,通过 Guard 支持演练模式、确认令牌和审计日志, ctx 取消时停止设置并回滚已修改的节点
+func ClusterConfigSetWithGuardContext(ctx context.Context, addrSlice []string, password, configKey, setValue string, g *Guard) (err error) {
+	return ClusterConfigSetWithOptionsContext(ctx, addrSlice, password, configKey, setValue, &ConfigSetOptions{Guard: g})
+}
+
+// ClusterConfigSetWithOptions 事务性地批量设置集群配置,详见 NodePool.ClusterConfigSetMulti
+func ClusterConfigSetWithOptions(addrSlice []string, password, configKey, setValue string, opts *ConfigSetOptions) (err error) {
+	return ClusterConfigSetWithOptionsContext(context.Background(), addrSlice, password, configKey, setValue, opts)
+}
+
+// ClusterConfigSetWithOptionsContext 事务性地批量设置集群配置, ctx 取消时停止设置并回滚已修改的节点
+func ClusterConfigSetWithOptionsContext(ctx context.Context, addrSlice []string, password, configKey, setValue string, opts *ConfigSetOptions) (err error) {
+	return ClusterConfigSetMultiContext(ctx, addrSlice, password, map[string]string{configKey: setValue}, opts)
+}
+
+// ClusterConfigSet 通过连接池事务性地批量设置集群配置, opts 为 nil 时按顺序设置,详见 NodePool.ClusterConfigSetMulti
+func (p *NodePool) ClusterConfigSet(ctx context.Context, addrSlice []string, configKey, setValue string, opts *ConfigSetOptions) (err error) {
+	return p.ClusterConfigSetMulti(ctx, addrSlice, map[string]string{configKey: setValue}, opts)
+}
+
+// ClusterConfigSetMulti 事务性地批量设置集群的多个配置项 {key: value},详见 NodePool.ClusterConfigSetMulti
+func ClusterConfigSetMulti(addrSlice []string, password string, values map[string]string, opts *ConfigSetOptions) (err error) {
+	return ClusterConfigSetMultiContext(context.Background(), addrSlice, password, values, opts)
+}
+
+// ClusterConfigSetMultiContext 事务性地批量设置集群的多个配置项, ctx 取消时停止设置并回滚已修改的节点
+func ClusterConfigSetMultiContext(ctx context.Context, addrSlice []string, password string, values map[string]string, opts *ConfigSetOptions) (err error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterConfigSetMulti(ctx, addrSlice, values, opts)
+}
+
+// ClusterConfigSetMulti 通过连接池事务性地批量设置集群的多个配置项 {key: value}:
+/*
+1.记录每个节点修改前的值,并校验集群配置是否一致
+2.按顺序或并发设置每个节点,redis 7 及以上版本通过一条 CONFIG SET 原子地设置多个配置项,低版本逐个设置
+3.按需在每个节点执行 CONFIG REWRITE
+4.读回每个节点的值,按值类型校验所有节点的值都已生效(如设置 "1gb" 读回 "1073741824" 视为生效)
+5.任意一步失败,将已经修改过的节点恢复为修改前的值(已经 REWRITE 的节点恢复后再次 REWRITE)
+*/
+func (p *NodePool) ClusterConfigSetMulti(ctx context.Context, addrSlice []string, values map[string]string, opts *ConfigSetOptions) (err error) {
+	// 校验确认令牌
+	if opts != nil {
+		if err := p.verifyCluster(ctx, addrSlice, opts.Guard); err != nil {
+			return err
+		}
+	}
+	return p.clusterConfigSet(ctx, addrSlice, values, opts)
+}
+
+// clusterConfigSet 事务性地批量设置集群的多个配置项,调用方负责校验确认令牌
+func (p *NodePool) clusterConfigSet(ctx context.Context, addrSlice []string, values map[string]string, opts *ConfigSetOptions) (err error) {
+	if opts == nil {
+		opts = &ConfigSetOptions{}
+	}
+	g := opts.Guard
+	l := p.logger()
+	defer logOperation(l, "ClusterConfigSet", "nodes", len(addrSlice), "keys", configKeys(values))(&err)
+
+	// 记录每个节点修改前的值,并校验集群配置是否一致
+	olds := make([]map[string]string, len(addrSlice))
+	_, err = eachNode(addrSlice, false, func(i int, addr string) (err error) {
+		olds[i], err = p.configGetKeys(ctx, addr, values)
+		if err != nil {
+			return err
+		}
+		for key := range values {
+			if !ConfigValueEqual(key, olds[0][key], olds[i][key]) {
+				return &ConfigMismatchError{Key: key, Values: map[string]string{
+					addrSlice[0]: olds[0][key],
+					addr:         olds[i][key],
+				}}
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	// 批量修改配置
-	for _, addr := range addrSlice {
-		// 连接 redis
-		rc, err := InitStandConn(addr, password)
-		if err != nil {
+	// 批量修改配置, setKeys 记录每个节点已经设置成功的配置项(低版本逐个设置时可能只设置了一部分)
+	setKeys := make([][]string, len(addrSlice))
+	_, err = eachNode(addrSlice, opts.Parallel, func(i int, addr string) (err error) {
+		setKeys[i], err = p.configSetKeys(ctx, addr, values, g)
+		return
+	})
+
+	// 持久化配置
+	var rewritten []bool
+	if err == nil && opts.Rewrite {
+		rewritten, err = eachNode(addrSlice, opts.Parallel, func(i int, addr string) error {
+			return p.configRewrite(ctx, addr, g)
+		})
+	}
+
+	// 校验所有节点的值都已生效
+	if err == nil && (g == nil || !g.DryRun) {
+		_, err = eachNode(addrSlice, opts.Parallel, func(i int, addr string) error {
+			news, err := p.configGetKeys(ctx, addr, values)
+			if err != nil {
+				return err
+			}
+			return configSetVerify(addr, values, news)
+		})
+	}
+	if err == nil {
+		return nil
+	}
+
+	// 回滚已经修改过的节点(包括只设置了部分配置项的节点), ctx 可能已经取消,回滚使用新的 ctx
+	l.Warn("设置集群配置失败, 开始回滚", "keys", configKeys(values), "err", err)
+	rbCtx := context.Background()
+	_, rbErr := eachNode(addrSlice, false, func(i int, addr string) error {
+		if len(setKeys[i]) == 0 {
+			return nil
+		}
+		restore := make(map[string]string, len(setKeys[i]))
+		for _, key := range setKeys[i] {
+			restore[key] = olds[i][key]
+		}
+		if err := p.configSet(rbCtx, addr, restore, g); err != nil {
 			return err
 		}
+		if rewritten != nil && rewritten[i] {
+			return p.configRewrite(rbCtx, addr, g)
+		}
+		return nil
+	})
+	if rbErr != nil {
+		return &RollbackError{Err: err, RollbackErr: rbErr}
+	}
+	return err
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		err = rc.ConfigSet(ctx, configKey, setValue).Err()
+// configGetKeys 获取单个节点上 values 中所有配置项当前的值
+func (p *NodePool) configGetKeys(ctx context.Context, addr string, values map[string]string) (map[string]string, error) {
+	ret := make(map[string]string, len(values))
+	for key := range values {
+		value, err := p.configGet(ctx, addr, key)
 		if err != nil {
-			errMsg := fmt.Sprintf("集群节点设置 %s 的值: %s 失败\n", configKey, setValue)
-			return errors.New(errMsg)
+			return nil, err
 		}
+		ret[key] = value
+	}
+	return ret, nil
+}
+
+// configSetVerify 按值类型校验设置后读回的值是否已经生效
+func configSetVerify(addr string, values, news map[string]string) error {
+	for key, value := range values {
+		if !ConfigValueEqual(key, value, news[key]) {
+			return &ConfigMismatchError{Key: key, Expected: value, Values: map[string]string{addr: news[key]}}
+		}
+	}
+	return nil
+}
+
+// eachNode 对每个节点执行 fn: parallel 为 true 时并发执行,否则按顺序执行并在第一个错误处停止,
+// 返回每个节点是否执行成功以及第一个错误(并发执行时按节点顺序)
+func eachNode(addrSlice []string, parallel bool, fn func(i int, addr string) error) (done []bool, err error) {
+	done = make([]bool, len(addrSlice))
+	errs := make([]error, len(addrSlice))
+	ran := len(addrSlice) // 顺序执行时实际执行过的节点数
+	if parallel {
+		var wg sync.WaitGroup
+		for i, addr := range addrSlice {
+			wg.Add(1)
+			go func(i int, addr string) {
+				defer wg.Done()
+				errs[i] = fn(i, addr)
+			}(i, addr)
+		}
+		wg.Wait()
+	} else {
+		for i, addr := range addrSlice {
+			if errs[i] = fn(i, addr); errs[i] != nil {
+				ran = i + 1
+				break
+			}
+		}
+	}
 
-		cancel()
-		rc.Close()
+	for i := 0; i < ran; i++ {
+		done[i] = errs[i] == nil
+		if errs[i] != nil && err == nil {
+			err = errs[i]
+		}
 	}
 	return
 }
 
+// configSet 设置单个节点的多个配置项, redis 7 及以上版本通过一条 CONFIG SET 原子地设置,低版本逐个设置
+func (p *NodePool) configSet(ctx context.Context, addr string, values map[string]string, g *Guard) error {
+	_, err := p.configSetKeys(ctx, addr, values, g)
+	return err
+}
+
+// configSetKeys 设置单个节点的多个配置项,返回已经设置成功的配置项, 低版本逐个设置失败时返回失败之前已设置的配置项
+func (p *NodePool) configSetKeys(ctx context.Context, addr string, values map[string]string, g *Guard) (done []string, err error) {
+	// 连接 redis
+	rc, release, err := p.acquire(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	keys := configKeys(values)
+	if len(keys) > 1 {
+		version, err := redisMajorVersion(ctx, rc, addr)
+		if err != nil {
+			return nil, err
+		}
+		if version >= 7 {
+			args := []interface{}{"config", "set"}
+			for _, key := range keys {
+				args = append(args, key, values[key])
+			}
+			err = p.do(ctx, g, rc, addr, args...)
+			if err != nil {
+				return nil, &NodeError{Addr: addr, Op: "CONFIG SET " + strings.Join(keys, " "), Err: err}
+			}
+			return keys, nil
+		}
+	}
+
+	for _, key := range keys {
+		err = p.do(ctx, g, rc, addr, "config", "set", key, values[key])
+		if err != nil {
+			return done, &NodeError{Addr: addr, Op: "CONFIG SET " + key, Err: err}
+		}
+		done = append(done, key)
+	}
+	return done, nil
+}
+
+// configKeys 获取排序后的配置项名称
+func configKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// redisMajorVersion 获取 redis 的主版本号
+func redisMajorVersion(ctx context.Context, rc *redis.Client, addr string) (int64, error) {
+	infoStr, err := rc.Info(ctx, "server").Result()
+	if err != nil {
+		return 0, &NodeError{Addr: addr, Op: "INFO server", Err: err}
+	}
+	infoMap, err := InfoMap(infoStr)
+	if err != nil {
+		return 0, err
+	}
+	versionPrefixStr := strings.Split(infoMap["redis_version"], ".")[0]
+	versionPrefix, err := strconv.ParseInt(versionPrefixStr, 10, 64)
+	if err != nil {
+		return 0, &ParseError{Addr: addr, Kind: "redis_version", Input: infoMap["redis_version"], Err: err}
+	}
+	return versionPrefix, nil
+}
+
+// configRewrite 将单个节点当前的配置写回配置文件
+func (p *NodePool) configRewrite(ctx context.Context, addr string, g *Guard) error {
+	// 连接 redis
+	rc, release, err := p.acquire(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err = p.do(ctx, g, rc, addr, "config", "rewrite")
+	if err != nil {
+		return &NodeError{Addr: addr, Op: "CONFIG REWRITE", Err: err}
+	}
+	return nil
+}
+
 // ==================================cluster flush==================================================
 
 // ClusterFLUSHALL 清空整个集群所有节点的数据
 func ClusterFLUSHALL(data *ClusterInfo, password, flushCMD string) (err error) {
-	clusterNodes := append(data.Masters, data.Slaves...)
+	return ClusterFLUSHALLContext(context.Background(), data, password, flushCMD)
+}
+
+// ClusterFLUSHALLContext 清空整个集群所有节点的数据, ctx 可用于取消耗时较长的清空操作
+func ClusterFLUSHALLContext(ctx context.Context, data *ClusterInfo, password, flushCMD string) (err error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterFLUSHALL(ctx, data, flushCMD, nil)
+}
+
+// ClusterFLUSHALLWithGuard 清空整个集群所有节点的数据,通过 Guard 支持演练模式、确认令牌和审计日志
+func ClusterFLUSHALLWithGuard(data *ClusterInfo, password, flushCMD string, g *Guard) (err error) {
+	return ClusterFLUSHALLWithGuardContext(context.Background(), data, password, flushCMD, g)
+}
+
+// ClusterFLUSHALLWithGuardContext 清空整个集群所有节点的数据,通过 Guard 支持演练模式、确认令牌和审计日志, ctx 可用于取消耗时较长的清空操作
+func ClusterFLUSHALLWithGuardContext(ctx context.Context, data *ClusterInfo, password, flushCMD string, g *Guard) (err error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterFLUSHALL(ctx, data, flushCMD, g)
+}
+
+// ClusterFLUSHALL 通过连接池清空整个集群所有节点的数据, g 不为 nil 时支持演练模式、确认令牌和审计日志
+func (p *NodePool) ClusterFLUSHALL(ctx context.Context, data *ClusterInfo, flushCMD string, g *Guard) (err error) {
+	clusterNodes := append(append([]string(nil), data.Masters...), data.Slaves...)
+	l := p.logger()
+	defer logOperation(l, "ClusterFLUSHALL", "nodes", len(clusterNodes), "cmd", flushCMD)(&err)
+
+	// 校验确认令牌, 令牌根据所有节点实时的 ID 计算
+	if err = p.verifyCluster(ctx, clusterNodes, g); err != nil {
+		return err
+	}
 
-	version3 := 0 // 标志位,用于标识当前集群版本是否是 redis 3.x 版本: 0 表示非 redis 3.x 版本;1 表示是 redis 3.x 版本
 	// 获取cluster-node-timeout配置值
-	ret, err := ClusterConfigGet(clusterNodes, password, "cluster-node-timeout")
+	ret, err := p.ClusterConfigGet(ctx, clusterNodes, "cluster-node-timeout")
 	if err != nil {
 		return err
 	}
 
+	version3 := false // 标志位,用于标识是否已经为 redis 3.x 版本调大了 cluster-node-timeout
 	for _, addr := range clusterNodes {
-		// 连接 redis
-		rc, err := InitStandConn(addr, password)
-		if err != nil {
-			return err
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
-
-		// 获取 redis 版本
-		infoStr, err := rc.Info(ctx).Result()
-		if err != nil {
-			return err
+		l.Info("清空节点数据", "addr", addr)
+		var raised bool
+		raised, err = p.flushNode(ctx, addr, flushCMD, clusterNodes, !version3, g)
+		if raised {
+			version3 = true
 		}
-		infoMap, err := InfoMap(infoStr)
 		if err != nil {
-			return err
+			break
 		}
-		versionPrefixStr := strings.Split(infoMap["redis_version"], ".")[0]
-		versionPrefix, err := strconv.ParseInt(versionPrefixStr, 10, 64)
-		if err != nil {
-			errMsg := fmt.Sprintf("获取redis: %s 版本失败\n", addr)
-			return errors.New(errMsg)
+	}
+
+	if version3 {
+		// 将cluster-node-timeout配置修改为原来配置的值, ctx 取消时也要恢复
+		if rErr := p.clusterConfigSet(context.Background(), clusterNodes, map[string]string{"cluster-node-timeout": ret}, &ConfigSetOptions{Guard: g}); rErr != nil && err == nil {
+			err = rErr
 		}
+	}
+	return
+}
 
-		// 针对不同版本的 redis, 执行不同的的清空操作
-		if versionPrefix == 3 { // redis 3.x 版本,清空会堵塞 redis,造成主从切换,需要先调整集群超时时间
-			if version3 == 0 {
-				// 调整将cluster-node-timeout配置项的值为 30 分钟,避免清空 redis 的时候发生主从切换
-				err = ClusterConfigSet(clusterNodes, password, "cluster-node-timeout", "1800")
-				if err != nil {
-					return err
-				}
-				version3 = 1
-			}
+// flushNode 清空单个节点的数据, raise 为 true 且节点为 redis 3.x 版本时会先调大集群的 cluster-node-timeout,
+// 返回值 raised 表示本次是否调大了 cluster-node-timeout
+func (p *NodePool) flushNode(ctx context.Context, addr, flushCMD string, clusterNodes []string, raise bool, g *Guard) (raised bool, err error) {
+	// 连接 redis
+	rc, release, err := p.acquire(ctx, addr)
+	if err != nil {
+		return false, err
+	}
+	defer release()
 
-			//对每个节点执行 FLUSHALL 命令
-			if flushCMD == "FLUSHALL" {
-				err = rc.FlushAll(ctx).Err()
-				if err != nil {
-					errMsg := fmt.Sprintf("执行 FLUSHALL 命令失败, err:%v\n", err)
-					return errors.New(errMsg)
-				}
-			} else {
-				err = rc.Do(ctx, flushCMD).Err()
-				if err != nil {
-					errMsg := fmt.Sprintf("执行 FLUSHALL 的 rename 命令: %s 失败, err:%v\n", flushCMD, err)
-					return errors.New(errMsg)
-				}
-			}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
 
-		} else if versionPrefix >= 4 { // redis 4 及以上版本,可以执行异步清空
-			//对每个节点执行 FLUSHALL 命令
-			if flushCMD == "FLUSHALL" {
-				err = rc.Do(ctx, "FLUSHALL", "ASYNC").Err()
-				if err != nil {
-					errMsg := fmt.Sprintf("执行 FLUSHALL ASYNC 命令失败, err:%v\n", err)
-					return errors.New(errMsg)
-				}
-			}
-		}
+	// 获取 redis 版本
+	versionPrefix, err := redisMajorVersion(ctx, rc, addr)
+	if err != nil {
+		return false, err
+	}
 
-		if version3 == 1 {
-			// 将cluster-node-timeout配置修改为原来配置的值
-			err = ClusterConfigSet(clusterNodes, password, "cluster-node-timeout", ret)
+	// 针对不同版本的 redis, 执行不同的的清空操作
+	if versionPrefix == 3 { // redis 3.x 版本,清空会堵塞 redis,造成主从切换,需要先调整集群超时时间
+		if raise {
+			// 调整将cluster-node-timeout配置项的值为 30 分钟(单位为毫秒),避免清空 redis 的时候发生主从切换
+			err = p.clusterConfigSet(ctx, clusterNodes, map[string]string{"cluster-node-timeout": "1800000"}, &ConfigSetOptions{Guard: g})
 			if err != nil {
-				return err
+				return false, err
 			}
+			raised = true
 		}
 
-		cancel()
-		rc.Close()
+		//对每个节点执行 FLUSHALL 命令
+		err = p.do(ctx, g, rc, addr, flushCMD)
+		if err != nil {
+			return raised, &NodeError{Addr: addr, Op: flushCMD, Err: err}
+		}
+
+	} else if versionPrefix >= 4 { // redis 4 及以上版本,可以执行异步清空
+		//对每个节点执行 FLUSHALL ASYNC 命令
+		err = p.do(ctx, g, rc, addr, flushCMD, "ASYNC")
+		if err != nil {
+			return raised, &NodeError{Addr: addr, Op: flushCMD + " ASYNC", Err: err}
+		}
 	}
-	return
+	return raised, nil
 }
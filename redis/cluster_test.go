@@ -0,0 +1,126 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeConfigNode 测试用的 redis 节点配置, reject 中的配置项 CONFIG SET 时返回错误
+type fakeConfigNode struct {
+	mu      sync.Mutex
+	version string
+	config  map[string]string
+	reject  map[string]bool
+}
+
+func (n *fakeConfigNode) handle(args []string) interface{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	switch strings.ToLower(args[0]) + " " + strings.ToLower(args[1]) {
+	case "info server":
+		return []byte("# Server\r\nredis_version:" + n.version + "\r\n")
+	case "config get":
+		return fakeFields(args[2], n.config[args[2]])
+	case "config set":
+		for i := 2; i+1 < len(args); i += 2 {
+			if n.reject[args[i]] {
+				return errors.New("ERR Invalid argument '" + args[i+1] + "' for CONFIG SET '" + args[i] + "'")
+			}
+		}
+		for i := 2; i+1 < len(args); i += 2 {
+			n.config[args[i]] = args[i+1]
+		}
+		return "OK"
+	}
+	return errors.New("ERR unknown command '" + args[0] + "'")
+}
+
+func (n *fakeConfigNode) get(key string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.config[key]
+}
+
+func TestClusterConfigSetMultiRollbackPartialNode(t *testing.T) {
+	for _, version := range []string{"6.2.6", "7.0.11"} {
+		t.Run(version, func(t *testing.T) {
+			nodes := []*fakeConfigNode{
+				{version: version, config: map[string]string{"maxmemory-samples": "5", "timeout": "0"}},
+				{version: version, config: map[string]string{"maxmemory-samples": "5", "timeout": "0"}, reject: map[string]bool{"timeout": true}},
+			}
+			addrs := []string{fakeRedis(t, nodes[0].handle), fakeRedis(t, nodes[1].handle)}
+
+			p := NewNodePool(nil)
+			defer p.Close()
+			err := p.ClusterConfigSetMulti(context.Background(), addrs, map[string]string{"maxmemory-samples": "10", "timeout": "300"}, nil)
+			var nodeErr *NodeError
+			if !errors.As(err, &nodeErr) || nodeErr.Addr != addrs[1] {
+				t.Fatalf("err = %v, want CONFIG SET error of %s", err, addrs[1])
+			}
+			for i, n := range nodes {
+				if got := n.get("maxmemory-samples"); got != "5" {
+					t.Errorf("node %d maxmemory-samples = %s, want rolled back to 5", i, got)
+				}
+				if got := n.get("timeout"); got != "0" {
+					t.Errorf("node %d timeout = %s, want rolled back to 0", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestClusterFLUSHALLVerifiesLiveNodeIDs(t *testing.T) {
+	var mu sync.Mutex
+	var flushed []string
+	ids := map[string]string{}
+	handler := func(addr *string) func(args []string) interface{} {
+		return func(args []string) interface{} {
+			mu.Lock()
+			defer mu.Unlock()
+			switch strings.ToLower(args[0]) {
+			case "cluster":
+				return []byte(ids[*addr])
+			case "flushall":
+				flushed = append(flushed, *addr)
+				return "OK"
+			}
+			return errors.New("ERR unknown command '" + args[0] + "'")
+		}
+	}
+	var master, slave string
+	master = fakeRedis(t, handler(&master))
+	slave = fakeRedis(t, handler(&slave))
+	mu.Lock()
+	ids[master], ids[slave] = "live-master", "live-slave"
+	mu.Unlock()
+
+	// 调用方的 ClusterInfo 已经过期, 其中的节点 ID 与实际节点不一致
+	stale := &ClusterInfo{
+		Masters:  []string{master},
+		Slaves:   []string{slave},
+		AddrToID: map[string]string{master: "old-master", slave: "old-slave"},
+	}
+	live := &ClusterInfo{AddrToID: map[string]string{master: "live-master", slave: "live-slave"}}
+
+	p := NewNodePool(nil)
+	defer p.Close()
+	err := p.ClusterFLUSHALL(context.Background(), stale, "flushall", &Guard{Confirm: ClusterToken(stale)})
+	if err != ErrConfirmMismatch {
+		t.Fatalf("err = %v, want %v", err, ErrConfirmMismatch)
+	}
+
+	// 演练模式获取的令牌根据实际节点 ID 计算(测试节点不支持后续的 CONFIG GET, 忽略演练的错误)
+	g := &Guard{DryRun: true}
+	_ = p.ClusterFLUSHALL(context.Background(), stale, "flushall", g)
+	if g.Token() != ClusterToken(live) {
+		t.Errorf("dry-run token = %s, want token of live node IDs %s", g.Token(), ClusterToken(live))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(flushed) != 0 {
+		t.Errorf("flushed %v, want no node flushed", flushed)
+	}
+}
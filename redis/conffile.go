@@ -0,0 +1,485 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ==================================redis.conf file==================================================
+
+// ConfDirective redis.conf 中的一条配置指令
+type ConfDirective struct {
+	Name string   // 指令名称,统一为小写
+	Args []string // 指令参数,已去掉引号并处理转义
+	File string   // 指令所在的文件
+	Line int      // 指令所在的行号
+}
+
+// ConfFile 解析后的 redis.conf 文件,include 的文件会按出现位置展开
+type ConfFile struct {
+	Directives []*ConfDirective
+}
+
+// multiConfDirectives 可以出现多次且每次都生效的指令,其余指令后出现的覆盖先出现的
+var multiConfDirectives = map[string]bool{
+	"save":                       true,
+	"rename-command":             true,
+	"client-output-buffer-limit": true,
+	"loadmodule":                 true,
+	"user":                       true,
+	"sentinel":                   true,
+}
+
+// nonConfigDirectives 不能通过 CONFIG GET 获取的指令,与实例实时配置比较时跳过
+var nonConfigDirectives = map[string]bool{
+	"include":        true,
+	"rename-command": true,
+	"loadmodule":     true,
+	"user":           true,
+	"sentinel":       true,
+}
+
+// ParseConfFile 解析 redis.conf 文件, include 的相对路径相对于当前文件所在目录
+func ParseConfFile(path string) (*ConfFile, error) {
+	conf := &ConfFile{}
+	if err := conf.parseFile(path, map[string]bool{}); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// ParseConf 解析 redis.conf 格式的内容, include 的相对路径相对于 dir
+func ParseConf(r io.Reader, dir string) (*ConfFile, error) {
+	conf := &ConfFile{}
+	if err := conf.parse(r, filepath.Join(dir, "-"), map[string]bool{}); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// parseFile 解析单个文件, visited 用于检测循环 include
+func (c *ConfFile) parseFile(path string, visited map[string]bool) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if visited[abs] {
+		return &FileError{Path: path, Err: ErrIncludeLoop}
+	}
+	visited[abs] = true
+	defer delete(visited, abs)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return &FileError{Path: path, Err: err}
+	}
+	defer f.Close()
+
+	return c.parse(f, path, visited)
+}
+
+// parse 逐行解析配置内容,遇到 include 指令时展开对应的文件
+func (c *ConfFile) parse(r io.Reader, path string, visited map[string]bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		args, err := splitConfArgs(line)
+		if err != nil {
+			return &FileError{Path: path, Line: lineNo, Err: err}
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		d := &ConfDirective{Name: strings.ToLower(args[0]), Args: args[1:], File: path, Line: lineNo}
+		if d.Name == "include" {
+			if len(d.Args) != 1 {
+				return &FileError{Path: path, Line: lineNo, Err: ErrInvalidInclude}
+			}
+			include := d.Args[0]
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(filepath.Dir(path), include)
+			}
+			if err := c.parseFile(include, visited); err != nil {
+				return err
+			}
+			continue
+		}
+		c.Directives = append(c.Directives, d)
+	}
+	if err := scanner.Err(); err != nil {
+		return &FileError{Path: path, Err: err}
+	}
+	return nil
+}
+
+// splitConfArgs 按 redis 的规则切分一行配置: 空白分隔,支持双引号(可转义)和单引号
+func splitConfArgs(line string) (args []string, err error) {
+	i := 0
+	for {
+		// 跳过空白
+		for i < len(line) && isConfSpace(line[i]) {
+			i++
+		}
+		if i >= len(line) {
+			return args, nil
+		}
+
+		var b strings.Builder
+		switch line[i] {
+		case '"':
+			i++
+			for {
+				if i >= len(line) {
+					return nil, ErrUnclosedQuote
+				}
+				ch := line[i]
+				if ch == '"' {
+					i++
+					break
+				}
+				if ch == '\\' && i+1 < len(line) {
+					i++
+					if line[i] == 'x' && i+2 < len(line) && isHex(line[i+1]) && isHex(line[i+2]) {
+						b.WriteByte(hexVal(line[i+1])<<4 | hexVal(line[i+2]))
+						i += 3
+						continue
+					}
+					b.WriteByte(unescapeConf(line[i]))
+					i++
+					continue
+				}
+				b.WriteByte(ch)
+				i++
+			}
+		case '\'':
+			i++
+			for {
+				if i >= len(line) {
+					return nil, ErrUnclosedQuote
+				}
+				ch := line[i]
+				if ch == '\'' {
+					i++
+					break
+				}
+				if ch == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					b.WriteByte('\'')
+					i += 2
+					continue
+				}
+				b.WriteByte(ch)
+				i++
+			}
+		default:
+			for i < len(line) && !isConfSpace(line[i]) {
+				b.WriteByte(line[i])
+				i++
+			}
+			args = append(args, b.String())
+			continue
+		}
+
+		// 引号闭合后必须是空白或行尾
+		if i < len(line) && !isConfSpace(line[i]) {
+			return nil, ErrQuoteNotSpaced
+		}
+		args = append(args, b.String())
+	}
+}
+
+func isConfSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n'
+}
+
+func isHex(ch byte) bool {
+	return (ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
+func hexVal(ch byte) byte {
+	switch {
+	case ch >= '0' && ch <= '9':
+		return ch - '0'
+	case ch >= 'a' && ch <= 'f':
+		return ch - 'a' + 10
+	default:
+		return ch - 'A' + 10
+	}
+}
+
+// unescapeConf 双引号内的转义字符
+func unescapeConf(ch byte) byte {
+	switch ch {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'b':
+		return '\b'
+	case 'a':
+		return '\a'
+	}
+	return ch
+}
+
+// Get 获取指定名称的所有指令
+func (c *ConfFile) Get(name string) (directives []*ConfDirective) {
+	name = strings.ToLower(name)
+	for _, d := range c.Directives {
+		if d.Name == name {
+			directives = append(directives, d)
+		}
+	}
+	return
+}
+
+// Effective 获取生效的指令: 可多次出现的指令全部保留,其余指令只保留最后一次出现的,
+// 保持指令在文件中的原有顺序(只保留一次的指令位于其最后一次出现的位置)
+func (c *ConfFile) Effective() (directives []*ConfDirective) {
+	last := make(map[string]int)
+	for i, d := range c.Directives {
+		last[d.Name] = i
+	}
+	for i, d := range c.Directives {
+		if multiConfDirectives[d.Name] || last[d.Name] == i {
+			directives = append(directives, d)
+		}
+	}
+	return
+}
+
+// Values 将生效的指令转换为与 CONFIG GET 返回格式一致的 {param: value},
+// 多次出现的 save、client-output-buffer-limit 合并为一个值,不能通过 CONFIG GET 获取的指令被跳过
+func (c *ConfFile) Values() map[string]string {
+	values := make(map[string]string)
+	obl := make(map[string]string) // client-output-buffer-limit: {class: limits}
+	for _, d := range c.Effective() {
+		if nonConfigDirectives[d.Name] {
+			continue
+		}
+		value := strings.Join(d.Args, " ")
+		switch d.Name {
+		case "save":
+			if value == "" || values["save"] == "" {
+				values["save"] = value
+			} else {
+				values["save"] += " " + value
+			}
+		case "client-output-buffer-limit":
+			if len(d.Args) > 0 {
+				class := strings.ToLower(d.Args[0])
+				if class == "replica" {
+					class = "slave"
+				}
+				obl[class] = strings.Join(d.Args[1:], " ")
+			}
+		default:
+			values[d.Name] = value
+		}
+	}
+
+	if len(obl) > 0 {
+		var parts []string
+		for _, class := range []string{"normal", "slave", "pubsub"} {
+			if limits, ok := obl[class]; ok {
+				parts = append(parts, class+" "+limits)
+			}
+		}
+		values["client-output-buffer-limit"] = strings.Join(parts, " ")
+	}
+	return values
+}
+
+// Render 将生效的指令写成规范化的配置文件: include 已展开,指令名称小写,每行一条,必要时加双引号
+func (c *ConfFile) Render(w io.Writer) error {
+	for _, d := range c.Effective() {
+		line := []string{d.Name}
+		for _, arg := range d.Args {
+			line = append(line, quoteConfArg(arg))
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(line, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quoteConfArg 参数为空或包含空白、引号、不可打印字符时加双引号并转义
+func quoteConfArg(arg string) string {
+	needQuote := arg == ""
+	for i := 0; i < len(arg) && !needQuote; i++ {
+		ch := arg[i]
+		needQuote = isConfSpace(ch) || ch == '"' || ch == '\'' || ch == '\\' || ch < 0x20 || ch >= 0x7f
+	}
+	if !needQuote {
+		return arg
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(arg); i++ {
+		ch := arg[i]
+		switch ch {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		default:
+			if ch < 0x20 || ch >= 0x7f {
+				fmt.Fprintf(&b, `\x%02x`, ch)
+			} else {
+				b.WriteByte(ch)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// ConfDiffItem 配置文件与实例实时配置不一致的配置项
+type ConfDiffItem struct {
+	Param     string
+	FileValue string // 配置文件中的值
+	LiveValue string // 实例实时的值
+	InLive    bool   // 实例是否支持该配置项
+}
+
+// Diff 按值类型比较配置文件与实例实时配置(CONFIG GET * 的结果),返回不一致的配置项,
+// 配置文件中没有的配置项使用实例默认值,不参与比较,密码类配置项按实际值比较,返回的值以 xxxxx 代替
+func (c *ConfFile) Diff(live map[string]string) (items []*ConfDiffItem) {
+	for param, fileValue := range c.Values() {
+		liveValue, ok := live[param]
+		if !ok {
+			// 兼容 slave/replica 的别名
+			if alias := confAlias(param); alias != "" {
+				liveValue, ok = live[alias]
+			}
+		}
+		if ok && param == "client-output-buffer-limit" {
+			// 配置文件中没有的客户端类型使用默认值,只比较配置文件中出现的类型
+			liveValue = pickBufferLimitClasses(liveValue, fileValue)
+		}
+		if ok && ConfigValueEqual(param, fileValue, liveValue) {
+			continue
+		}
+		items = append(items, &ConfDiffItem{
+			Param:     param,
+			FileValue: redactSecret(param, fileValue),
+			LiveValue: redactSecret(param, liveValue),
+			InLive:    ok,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Param < items[j].Param
+	})
+	return
+}
+
+// pickBufferLimitClasses 从 client-output-buffer-limit 的实时值中取出 ref 中出现的客户端类型,
+// 格式为 "class hard soft seconds ..."
+func pickBufferLimitClasses(live, ref string) string {
+	classes := make(map[string]bool)
+	refFields := strings.Fields(ref)
+	for i := 0; i+3 < len(refFields); i += 4 {
+		classes[refFields[i]] = true
+	}
+
+	var parts []string
+	liveFields := strings.Fields(live)
+	for i := 0; i+3 < len(liveFields); i += 4 {
+		if classes[liveFields[i]] {
+			parts = append(parts, liveFields[i:i+4]...)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// confAlias 获取 slave/replica 相关配置项的别名
+func confAlias(param string) string {
+	switch {
+	case strings.Contains(param, "replica"):
+		return strings.Replace(param, "replica", "slave", -1)
+	case strings.Contains(param, "slave"):
+		return strings.Replace(param, "slave", "replica", -1)
+	}
+	return ""
+}
+
+// ConfFileDiff 比较配置文件与 redis 实例的实时配置
+func ConfFileDiff(addr, password string, conf *ConfFile) ([]*ConfDiffItem, error) {
+	return ConfFileDiffContext(context.Background(), addr, password, conf)
+}
+
+// ConfFileDiffContext 比较配置文件与 redis 实例的实时配置, ctx 取消时立即返回
+func ConfFileDiffContext(ctx context.Context, addr, password string, conf *ConfFile) ([]*ConfDiffItem, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ConfFileDiff(ctx, addr, conf)
+}
+
+// ConfFileDiff 通过连接池比较配置文件与 redis 实例的实时配置
+func (p *NodePool) ConfFileDiff(ctx context.Context, addr string, conf *ConfFile) (_ []*ConfDiffItem, err error) {
+	defer logOperation(p.logger(), "ConfFileDiff", "addr", addr)(&err)
+
+	live, err := p.configGetAll(ctx, addr, "*")
+	if err != nil {
+		return nil, err
+	}
+	return conf.Diff(live), nil
+}
+
+// ClusterConfFileDiff 并发比较配置文件与集群每个节点的实时配置 {addr: [item, ...]}
+func ClusterConfFileDiff(addrSlice []string, password string, conf *ConfFile) (map[string][]*ConfDiffItem, error) {
+	return ClusterConfFileDiffContext(context.Background(), addrSlice, password, conf)
+}
+
+// ClusterConfFileDiffContext 并发比较配置文件与集群每个节点的实时配置, ctx 取消时立即返回
+func ClusterConfFileDiffContext(ctx context.Context, addrSlice []string, password string, conf *ConfFile) (map[string][]*ConfDiffItem, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterConfFileDiff(ctx, addrSlice, conf)
+}
+
+// ClusterConfFileDiff 通过连接池并发比较配置文件与集群每个节点的实时配置 {addr: [item, ...]}
+func (p *NodePool) ClusterConfFileDiff(ctx context.Context, addrSlice []string, conf *ConfFile) (_ map[string][]*ConfDiffItem, err error) {
+	defer logOperation(p.logger(), "ClusterConfFileDiff", "nodes", len(addrSlice))(&err)
+
+	diffs := make([][]*ConfDiffItem, len(addrSlice))
+	_, err = eachNode(addrSlice, true, func(i int, addr string) (err error) {
+		diffs[i], err = p.ConfFileDiff(ctx, addr, conf)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string][]*ConfDiffItem, len(addrSlice))
+	for i, addr := range addrSlice {
+		ret[addr] = diffs[i]
+	}
+	return ret, nil
+}
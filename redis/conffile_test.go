@@ -0,0 +1,188 @@
+package redis
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitConfArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+		err  error
+	}{
+		{line: "", want: nil},
+		{line: "maxmemory 1gb", want: []string{"maxmemory", "1gb"}},
+		{line: "  save\t900  1 ", want: []string{"save", "900", "1"}},
+		{line: `requirepass "a b"`, want: []string{"requirepass", "a b"}},
+		{line: `requirepass ""`, want: []string{"requirepass", ""}},
+		{line: `rename-command "a\"b\n\x41"`, want: []string{"rename-command", "a\"b\nA"}},
+		{line: `masterauth 'it\'s'`, want: []string{"masterauth", "it's"}},
+		{line: `masterauth 'a\nb'`, want: []string{"masterauth", `a\nb`}},
+		{line: `requirepass "abc`, err: ErrUnclosedQuote},
+		{line: `requirepass 'abc`, err: ErrUnclosedQuote},
+		{line: `requirepass "abc"def`, err: ErrQuoteNotSpaced},
+	}
+	for _, tt := range tests {
+		got, err := splitConfArgs(tt.line)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("splitConfArgs(%q) err = %v, want %v", tt.line, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitConfArgs(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseConf(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "common.conf"), []byte("maxmemory 2gb\nsave 60 10000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "loop.conf"), []byte("include loop.conf\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string // "name args..." 每条指令一项
+		line    int      // 出错的行号
+		err     error
+	}{
+		{
+			name:    "comments and case",
+			content: "# comment\n\nPort 6379\n  MaxMemory 1gb\n",
+			want:    []string{"port 6379", "maxmemory 1gb"},
+		},
+		{
+			name:    "include",
+			content: "save 900 1\ninclude common.conf\nappendonly yes\n",
+			want:    []string{"save 900 1", "maxmemory 2gb", "save 60 10000", "appendonly yes"},
+		},
+		{
+			name:    "include loop",
+			content: "include loop.conf\n",
+			err:     ErrIncludeLoop,
+		},
+		{
+			name:    "include without args",
+			content: "port 6379\ninclude\n",
+			line:    2,
+			err:     ErrInvalidInclude,
+		},
+		{
+			name:    "unclosed quote",
+			content: "port 6379\n\nrequirepass \"abc\n",
+			line:    3,
+			err:     ErrUnclosedQuote,
+		},
+		{
+			name:    "missing include",
+			content: "include missing.conf\n",
+			err:     os.ErrNotExist,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := ParseConf(strings.NewReader(tt.content), dir)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				var fileErr *FileError
+				if !errors.As(err, &fileErr) {
+					t.Fatalf("err = %T, want *FileError", err)
+				}
+				if fileErr.Line != tt.line {
+					t.Errorf("line = %d, want %d", fileErr.Line, tt.line)
+				}
+				return
+			}
+			var got []string
+			for _, d := range conf.Directives {
+				got = append(got, strings.Join(append([]string{d.Name}, d.Args...), " "))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("directives = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfFileEffective(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "last single value wins",
+			content: "maxmemory 1gb\nport 6379\nmaxmemory 2gb\n",
+			want:    []string{"port 6379", "maxmemory 2gb"},
+		},
+		{
+			name:    "multi value directives kept",
+			content: "save 900 1\nmaxmemory 1gb\nsave 300 10\n",
+			want:    []string{"save 900 1", "maxmemory 1gb", "save 300 10"},
+		},
+		{
+			name:    "single occurrence keeps order",
+			content: "port 6379\nbind 0.0.0.0\nappendonly yes\n",
+			want:    []string{"port 6379", "bind 0.0.0.0", "appendonly yes"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := ParseConf(strings.NewReader(tt.content), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range conf.Effective() {
+				got = append(got, strings.Join(append([]string{d.Name}, d.Args...), " "))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Effective() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfFileValues(t *testing.T) {
+	content := "save 900 1\nsave 300 10\nmaxmemory 1gb\nmaxmemory 2gb\n" +
+		"client-output-buffer-limit pubsub 32mb 8mb 60\nclient-output-buffer-limit replica 256mb 64mb 60\n" +
+		"rename-command FLUSHALL \"\"\n"
+	conf, err := ParseConf(strings.NewReader(content), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"save":                       "900 1 300 10",
+		"maxmemory":                  "2gb",
+		"client-output-buffer-limit": "slave 256mb 64mb 60 pubsub 32mb 8mb 60",
+	}
+	if got := conf.Values(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() = %v, want %v", got, want)
+	}
+}
+
+func TestConfFileDiffRedactsSecrets(t *testing.T) {
+	conf, err := ParseConf(strings.NewReader("requirepass file-secret\nmasterauth same-secret\nmaxmemory 1gb\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := map[string]string{"requirepass": "live-secret", "masterauth": "same-secret", "maxmemory": "0"}
+	want := []*ConfDiffItem{
+		{Param: "maxmemory", FileValue: "1gb", LiveValue: "0", InLive: true},
+		{Param: "requirepass", FileValue: "xxxxx", LiveValue: "xxxxx", InLive: true},
+	}
+	if got := conf.Diff(live); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %+v, want %+v", got, want)
+	}
+}
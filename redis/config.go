@@ -0,0 +1,180 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================================config value==================================================
+
+// ConfigKind 配置项的值类型
+type ConfigKind int
+
+const (
+	ConfigString   ConfigKind = iota // 字符串,按空白分隔的每一项逐个比较
+	ConfigMemory                     // 内存大小,支持 k、kb、m、mb、g、gb 单位
+	ConfigDuration                   // 时长,整数按配置项自身的单位换算,也支持 "10s" 这样的写法
+	ConfigBool                       // yes/no
+)
+
+// memoryConfigs 值为内存大小的配置项, client-output-buffer-limit 等多个值组合的配置项按字符串类型逐项比较
+var memoryConfigs = map[string]bool{
+	"maxmemory":                  true,
+	"maxmemory-clients":          true,
+	"client-query-buffer-limit":  true,
+	"proto-max-bulk-len":         true,
+	"repl-backlog-size":          true,
+	"auto-aof-rewrite-min-size":  true,
+	"active-defrag-ignore-bytes": true,
+	"stream-node-max-bytes":      true,
+}
+
+// durationConfigs 值为时长的配置项及其单位
+var durationConfigs = map[string]time.Duration{
+	"timeout":                   time.Second,
+	"tcp-keepalive":             time.Second,
+	"repl-timeout":              time.Second,
+	"repl-ping-replica-period":  time.Second,
+	"repl-ping-slave-period":    time.Second,
+	"repl-backlog-ttl":          time.Second,
+	"repl-diskless-sync-delay":  time.Second,
+	"min-replicas-max-lag":      time.Second,
+	"min-slaves-max-lag":        time.Second,
+	"cluster-node-timeout":      time.Millisecond,
+	"lua-time-limit":            time.Millisecond,
+	"busy-reply-threshold":      time.Millisecond,
+	"latency-monitor-threshold": time.Millisecond,
+	"watchdog-period":           time.Millisecond,
+	"slowlog-log-slower-than":   time.Microsecond,
+}
+
+// ConfigKindOf 获取配置项的值类型,时长类型同时返回整数值对应的单位
+func ConfigKindOf(key string) (ConfigKind, time.Duration) {
+	key = strings.ToLower(key)
+	if unit, ok := durationConfigs[key]; ok {
+		return ConfigDuration, unit
+	}
+	if memoryConfigs[key] {
+		return ConfigMemory, 0
+	}
+	return ConfigString, 0
+}
+
+// ConfigValue 解析后的配置项的值
+type ConfigValue struct {
+	Key      string
+	Raw      string        // 原始值
+	Kind     ConfigKind    // 值类型
+	Bytes    int64         // Kind 为 ConfigMemory 时的字节数
+	Duration time.Duration // Kind 为 ConfigDuration 时的时长
+	Bool     bool          // Kind 为 ConfigBool 时的值
+}
+
+// ParseConfigValue 按配置项的值类型解析配置值
+func ParseConfigValue(key, raw string) (v ConfigValue, err error) {
+	v = ConfigValue{Key: key, Raw: raw}
+	kind, unit := ConfigKindOf(key)
+	switch {
+	case kind == ConfigMemory:
+		v.Kind = kind
+		v.Bytes, err = ParseMemory(raw)
+	case kind == ConfigDuration:
+		v.Kind = kind
+		v.Duration, err = ParseDuration(raw, unit)
+	case strings.EqualFold(raw, "yes") || strings.EqualFold(raw, "no"):
+		v.Kind = ConfigBool
+		v.Bool = strings.EqualFold(raw, "yes")
+	default:
+		v.Kind = ConfigString
+	}
+	if err != nil {
+		return v, &ParseError{Kind: "config " + key, Input: raw, Err: err}
+	}
+	return v, nil
+}
+
+// Equal 按值类型比较两个配置值是否相等,如 maxmemory 的 "1gb" 与 "1073741824" 相等
+func (v ConfigValue) Equal(o ConfigValue) bool {
+	if v.Kind != o.Kind {
+		return false
+	}
+	switch v.Kind {
+	case ConfigMemory:
+		return v.Bytes == o.Bytes
+	case ConfigDuration:
+		return v.Duration == o.Duration
+	case ConfigBool:
+		return v.Bool == o.Bool
+	}
+
+	// 字符串类型按空白分隔后逐项比较,每一项能按内存大小解析时按字节数比较
+	a, b := strings.Fields(v.Raw), strings.Fields(o.Raw)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if strings.EqualFold(a[i], b[i]) {
+			continue
+		}
+		ab, aErr := ParseMemory(a[i])
+		bb, bErr := ParseMemory(b[i])
+		if aErr != nil || bErr != nil || ab != bb {
+			return false
+		}
+	}
+	return true
+}
+
+// ConfigValueEqual 按配置项的值类型比较两个配置值是否相等,无法解析时按原始字符串比较
+func ConfigValueEqual(key, a, b string) bool {
+	if a == b {
+		return true
+	}
+	av, aErr := ParseConfigValue(key, a)
+	bv, bErr := ParseConfigValue(key, b)
+	if aErr != nil || bErr != nil {
+		return false
+	}
+	return av.Equal(bv)
+}
+
+// memoryUnits redis 配置文件支持的内存单位
+var memoryUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"kb", 1 << 10},
+	{"mb", 1 << 20},
+	{"gb", 1 << 30},
+	{"k", 1000},
+	{"m", 1000 * 1000},
+	{"g", 1000 * 1000 * 1000},
+	{"b", 1},
+}
+
+// ParseMemory 解析内存大小,单位与 redis.conf 一致(不区分大小写): 1k => 1000 bytes, 1kb => 1024 bytes,
+// 1m => 1000000 bytes, 1mb => 1048576 bytes, g 和 gb 同理,不带单位时为字节数
+func ParseMemory(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, unit := range memoryUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			n, err := strconv.ParseInt(strings.TrimSuffix(s, unit.suffix), 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return n * unit.size, nil
+		}
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// ParseDuration 解析时长,纯整数按 unit 换算(如 cluster-node-timeout 的单位为毫秒),
+// 也支持 time.ParseDuration 的写法(如 "15s"、"500ms")
+func ParseDuration(s string, unit time.Duration) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(n) * unit, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,98 @@
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+		err   bool
+	}{
+		{input: "0", want: 0},
+		{input: "1073741824", want: 1073741824},
+		{input: "1gb", want: 1 << 30},
+		{input: "1GB", want: 1 << 30},
+		{input: " 1Gb ", want: 1 << 30},
+		{input: "1g", want: 1000 * 1000 * 1000},
+		{input: "100kb", want: 100 << 10},
+		{input: "100k", want: 100 * 1000},
+		{input: "64mb", want: 64 << 20},
+		{input: "64M", want: 64 * 1000 * 1000},
+		{input: "512b", want: 512},
+		{input: "", err: true},
+		{input: "gb", err: true},
+		{input: "1.5gb", err: true},
+		{input: "1tb", err: true},
+		{input: "abc", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMemory(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseMemory(%q) err = %v, want error %v", tt.input, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMemory(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		unit  time.Duration
+		want  time.Duration
+		err   bool
+	}{
+		{input: "15000", unit: time.Millisecond, want: 15 * time.Second},
+		{input: "300", unit: time.Second, want: 5 * time.Minute},
+		{input: " 10000 ", unit: time.Microsecond, want: 10 * time.Millisecond},
+		{input: "15s", unit: time.Millisecond, want: 15 * time.Second},
+		{input: "500ms", unit: time.Second, want: 500 * time.Millisecond},
+		{input: "", unit: time.Second, err: true},
+		{input: "15S", unit: time.Second, err: true},
+		{input: "abc", unit: time.Second, err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.input, tt.unit)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseDuration(%q, %v) err = %v, want error %v", tt.input, tt.unit, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q, %v) = %v, want %v", tt.input, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestConfigValueEqual(t *testing.T) {
+	tests := []struct {
+		key, a, b string
+		want      bool
+	}{
+		{key: "maxmemory", a: "1gb", b: "1073741824", want: true},
+		{key: "maxmemory", a: "1GB", b: "1gb", want: true},
+		{key: "MAXMEMORY", a: "1gb", b: "1073741824", want: true},
+		{key: "maxmemory", a: "1g", b: "1073741824", want: false},
+		{key: "maxmemory", a: "1gb", b: "1x", want: false},
+		{key: "maxmemory", a: "abc", b: "abc", want: true},
+		{key: "cluster-node-timeout", a: "15000", b: "15s", want: true},
+		{key: "timeout", a: "300", b: "5m", want: true},
+		{key: "timeout", a: "300", b: "301", want: false},
+		{key: "appendonly", a: "yes", b: "YES", want: true},
+		{key: "appendonly", a: "yes", b: "no", want: false},
+		{key: "save", a: "3600 1  300 100", b: "3600 1 300 100", want: true},
+		{key: "save", a: "3600 1", b: "3600 1 300 100", want: false},
+		{key: "client-output-buffer-limit", a: "normal 0 0 0 slave 256mb 64mb 60", b: "normal 0 0 0 slave 268435456 67108864 60", want: true},
+		{key: "maxmemory-policy", a: "allkeys-LRU", b: "allkeys-lru", want: true},
+		{key: "maxmemory-policy", a: "allkeys-lru", b: "allkeys-lfu", want: false},
+	}
+	for _, tt := range tests {
+		if got := ConfigValueEqual(tt.key, tt.a, tt.b); got != tt.want {
+			t.Errorf("ConfigValueEqual(%q, %q, %q) = %v, want %v", tt.key, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
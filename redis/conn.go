@@ -2,109 +2,344 @@ package redis
 
 import (
 	"context"
-	"errors"
-	"fmt"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
-//InitStandConn 初始化单例 redis 连接
+// ConnOptions redis 连接选项,所有连接函数共用同一份选项,零值字段使用 go-redis 的默认值
+type ConnOptions struct {
+	Username         string // ACL 用户名, redis 6 及以上版本使用
+	Password         string // 密码
+	SentinelUsername string // 哨兵节点的 ACL 用户名,只在 SentinelPassword 不为空时用于哨兵管理连接, go-redis 的哨兵 master/slave 连接不支持
+	SentinelPassword string // 哨兵节点的密码,为空时与 Password 相同
+	DB               int    // 数据库编号,只对单实例和哨兵 master 连接生效
+
+	TLS *TLSOptions // 不为 nil 时使用 TLS 连接
+
+	DialTimeout  time.Duration // 建立连接超时时间
+	ReadTimeout  time.Duration // 读超时时间
+	WriteTimeout time.Duration // 写超时时间
+
+	PoolSize     int // 连接池大小
+	MinIdleConns int // 连接池最小空闲连接数
+
+	ClientName string // 连接建立后通过 CLIENT SETNAME 设置的连接名称
+
+	Logger Logger // 日志,为空时使用 SetLogger 设置的默认日志
+}
+
+// TLSOptions TLS 连接选项
+type TLSOptions struct {
+	CAFile             string // CA 证书文件,为空时使用系统证书
+	CertFile           string // 客户端证书文件,双向认证时使用
+	KeyFile            string // 客户端私钥文件,双向认证时使用
+	ServerName         string // SNI,为空时使用连接地址中的主机名
+	InsecureSkipVerify bool   // 跳过服务端证书校验,仅用于测试环境
+}
+
+// Config 根据 TLS 连接选项生成 tls.Config
+func (t *TLSOptions) Config() (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		ca, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, &FileError{Path: t.CAFile, Err: err}
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, &FileError{Path: t.CAFile, Err: ErrNoCACert}
+		}
+		conf.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, &FileError{Path: t.CertFile, Err: err}
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// connOptions 为 nil 的连接选项使用零值选项
+func connOptions(opts *ConnOptions) *ConnOptions {
+	if opts == nil {
+		return &ConnOptions{}
+	}
+	return opts
+}
+
+// tlsConfig 获取连接选项对应的 tls.Config,未开启 TLS 时返回 nil
+func (o *ConnOptions) tlsConfig() (*tls.Config, error) {
+	if o.TLS == nil {
+		return nil, nil
+	}
+	return o.TLS.Config()
+}
+
+// onConnect 连接建立后设置连接名称
+func (o *ConnOptions) onConnect() func(ctx context.Context, cn *redis.Conn) error {
+	if o.ClientName == "" {
+		return nil
+	}
+	name := o.ClientName
+	return func(ctx context.Context, cn *redis.Conn) error {
+		return cn.ClientSetName(ctx, name).Err()
+	}
+}
+
+// standOptions 生成单实例连接的 redis.Options
+func (o *ConnOptions) standOptions(addr string) (*redis.Options, error) {
+	tlsConf, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &redis.Options{
+		Addr:         addr,
+		Username:     o.Username,
+		Password:     o.Password,
+		DB:           o.DB,
+		TLSConfig:    tlsConf,
+		DialTimeout:  o.DialTimeout,
+		ReadTimeout:  o.ReadTimeout,
+		WriteTimeout: o.WriteTimeout,
+		PoolSize:     o.PoolSize,
+		MinIdleConns: o.MinIdleConns,
+		OnConnect:    o.onConnect(),
+	}, nil
+}
+
+// failoverOptions 生成哨兵连接的 redis.FailoverOptions
+func (o *ConnOptions) failoverOptions(addrSlice []string, masterName string) (*redis.FailoverOptions, error) {
+	tlsConf, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	sentinelPassword := o.SentinelPassword
+	if sentinelPassword == "" {
+		sentinelPassword = o.Password
+	}
+	return &redis.FailoverOptions{
+		MasterName:       masterName,
+		SentinelAddrs:    addrSlice,
+		SentinelPassword: sentinelPassword,
+		Username:         o.Username,
+		Password:         o.Password,
+		DB:               o.DB,
+		TLSConfig:        tlsConf,
+		DialTimeout:      o.DialTimeout,
+		ReadTimeout:      o.ReadTimeout,
+		WriteTimeout:     o.WriteTimeout,
+		PoolSize:         o.PoolSize,
+		MinIdleConns:     o.MinIdleConns,
+		OnConnect:        o.onConnect(),
+	}, nil
+}
+
+// clusterOptions 生成集群连接的 redis.ClusterOptions
+func (o *ConnOptions) clusterOptions(addrSlice []string) (*redis.ClusterOptions, error) {
+	tlsConf, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &redis.ClusterOptions{
+		Addrs:        addrSlice,
+		Username:     o.Username,
+		Password:     o.Password,
+		TLSConfig:    tlsConf,
+		DialTimeout:  o.DialTimeout,
+		ReadTimeout:  o.ReadTimeout,
+		WriteTimeout: o.WriteTimeout,
+		PoolSize:     o.PoolSize,
+		MinIdleConns: o.MinIdleConns,
+		OnConnect:    o.onConnect(),
+	}, nil
+}
+
+// InitStandConn 初始化单例 redis 连接
 func InitStandConn(addr, password string) (*redis.Client, error) {
-	rc := redis.NewClient(&redis.Options{
-		Addr:        addr,
+	return InitStandConnWithOptions(addr, &ConnOptions{
 		Password:    password,
-		DB:          0,
 		PoolSize:    100,
 		DialTimeout: time.Minute * 30,
 	})
+}
+
+// InitStandConnWithOptions 通过连接选项初始化单例 redis 连接
+func InitStandConnWithOptions(addr string, opts *ConnOptions) (*redis.Client, error) {
+	return initStandConn(context.Background(), addr, opts)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// initStandConn 初始化单例 redis 连接, ctx 用于取消 PING 校验
+func initStandConn(ctx context.Context, addr string, opts *ConnOptions) (*redis.Client, error) {
+	opts = connOptions(opts)
+	redisOpts, err := opts.standOptions(addr)
+	if err != nil {
+		return nil, err
+	}
+	rc := redis.NewClient(redisOpts)
+	rc.AddHook(commandHook{addr: addr, opts: opts})
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	_, err := rc.Ping(ctx).Result()
+	_, err = rc.Ping(ctx).Result()
 	if err != nil {
-		errMsg := fmt.Sprintf("redis 实例 %s 连接失败: %v\n", addr, err)
-		return nil, errors.New(errMsg)
+		rc.Close()
+		return nil, &NodeError{Addr: addr, Op: "connect", Err: err}
 	}
 	return rc, nil
 }
 
-//InitSentinelMasterConn 初始化哨兵连接,通过哨兵获取到对应 master name 节点的 master 连接
+// InitSentinelMasterConn 初始化哨兵连接,通过哨兵获取到对应 master name 节点的 master 连接
 func InitSentinelMasterConn(addrSlice []string, password, masterName string) (*redis.Client, error) {
-	rc := redis.NewFailoverClient(&redis.FailoverOptions{
-		MasterName:    masterName,
-		SentinelAddrs: addrSlice,
-		Password:      password,
-		PoolSize:      1000,
-		DialTimeout:   time.Minute * 30,
+	return InitSentinelMasterConnWithOptions(addrSlice, masterName, &ConnOptions{
+		Password:    password,
+		PoolSize:    1000,
+		DialTimeout: time.Minute * 30,
 	})
+}
+
+// InitSentinelMasterConnWithOptions 通过连接选项初始化哨兵连接,获取到对应 master name 节点的 master 连接
+func InitSentinelMasterConnWithOptions(addrSlice []string, masterName string, opts *ConnOptions) (*redis.Client, error) {
+	return initSentinelMasterConn(context.Background(), addrSlice, masterName, opts)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// initSentinelMasterConn 初始化哨兵 master 连接, ctx 用于取消 PING 校验
+func initSentinelMasterConn(ctx context.Context, addrSlice []string, masterName string, opts *ConnOptions) (*redis.Client, error) {
+	opts = connOptions(opts)
+	failoverOpts, err := opts.failoverOptions(addrSlice, masterName)
+	if err != nil {
+		return nil, err
+	}
+	rc := redis.NewFailoverClient(failoverOpts)
+	rc.AddHook(commandHook{addr: strings.Join(addrSlice, ","), opts: opts})
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	_, err := rc.Ping(ctx).Result()
+	_, err = rc.Ping(ctx).Result()
 	if err != nil {
-		errMsg := fmt.Sprintf("哨兵 %v 上 %s 的 master 连接失败: %v\n", addrSlice, masterName, err)
-		return nil, errors.New(errMsg)
+		rc.Close()
+		return nil, &NodeError{Addr: strings.Join(addrSlice, ","), Op: "connect master " + masterName, Err: err}
 	}
 	return rc, nil
 }
 
-//InitSentinelSlaveConn 初始化哨兵连接,通过哨兵获取到对应 master name 节点的 slave 只读连接
+// InitSentinelSlaveConn 初始化哨兵连接,通过哨兵获取到对应 master name 节点的 slave 只读连接
 func InitSentinelSlaveConn(addrSlice []string, password, masterName string) (*redis.ClusterClient, error) {
-	rc := redis.NewFailoverClusterClient(&redis.FailoverOptions{
-		MasterName:    masterName,
-		SentinelAddrs: addrSlice,
-		Password:      password,
-		PoolSize:      1000,
-		DialTimeout:   time.Minute * 30,
+	return InitSentinelSlaveConnWithOptions(addrSlice, masterName, &ConnOptions{
+		Password:    password,
+		PoolSize:    1000,
+		DialTimeout: time.Minute * 30,
 	})
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// InitSentinelSlaveConnWithOptions 通过连接选项初始化哨兵连接,获取到对应 master name 节点的 slave 只读连接
+func InitSentinelSlaveConnWithOptions(addrSlice []string, masterName string, opts *ConnOptions) (*redis.ClusterClient, error) {
+	return initSentinelSlaveConn(context.Background(), addrSlice, masterName, opts)
+}
+
+// initSentinelSlaveConn 初始化哨兵 slave 只读连接, ctx 用于取消 PING 校验
+func initSentinelSlaveConn(ctx context.Context, addrSlice []string, masterName string, opts *ConnOptions) (*redis.ClusterClient, error) {
+	opts = connOptions(opts)
+	failoverOpts, err := opts.failoverOptions(addrSlice, masterName)
+	if err != nil {
+		return nil, err
+	}
+	rc := redis.NewFailoverClusterClient(failoverOpts)
+	rc.AddHook(commandHook{addr: strings.Join(addrSlice, ","), opts: opts})
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	_, err := rc.Ping(ctx).Result()
+	_, err = rc.Ping(ctx).Result()
 	if err != nil {
-		errMsg := fmt.Sprintf("哨兵 %v 上 %s 的 slave 连接失败: %v\n", addrSlice, masterName, err)
-		return nil, errors.New(errMsg)
+		rc.Close()
+		return nil, &NodeError{Addr: strings.Join(addrSlice, ","), Op: "connect slave " + masterName, Err: err}
 	}
 	return rc, nil
 }
 
-//InitSentinelManagerConn 初始化哨兵管理连接,用于连接哨兵节点,管理哨兵
+// InitSentinelManagerConn 初始化哨兵管理连接,用于连接哨兵节点,管理哨兵
 func InitSentinelManagerConn(addr, password string) (*redis.SentinelClient, error) {
-	rc := redis.NewSentinelClient(&redis.Options{
-		Addr:     addr,
+	return InitSentinelManagerConnWithOptions(addr, &ConnOptions{
 		Password: password,
 		PoolSize: 100,
 	})
+}
+
+// InitSentinelManagerConnWithOptions 通过连接选项初始化哨兵管理连接,
+// SentinelPassword 不为空时使用 SentinelUsername 和 SentinelPassword 认证,否则使用 Username 和 Password
+func InitSentinelManagerConnWithOptions(addr string, opts *ConnOptions) (*redis.SentinelClient, error) {
+	return initSentinelManagerConn(context.Background(), addr, opts)
+}
+
+// initSentinelManagerConn 初始化哨兵管理连接, ctx 用于取消 PING 校验
+func initSentinelManagerConn(ctx context.Context, addr string, opts *ConnOptions) (*redis.SentinelClient, error) {
+	opts = connOptions(opts)
+	redisOpts, err := opts.standOptions(addr)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SentinelPassword != "" {
+		redisOpts.Username = opts.SentinelUsername
+		redisOpts.Password = opts.SentinelPassword
+	}
+	redisOpts.DB = 0
+	rc := redis.NewSentinelClient(redisOpts)
+	rc.AddHook(commandHook{addr: addr, opts: opts})
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	_, err := rc.Ping(ctx).Result()
+	_, err = rc.Ping(ctx).Result()
 	if err != nil {
-		errMsg := fmt.Sprintf("哨兵管理节点: %s 连接失败: %v\n", addr, err)
-		return nil, errors.New(errMsg)
+		rc.Close()
+		return nil, &NodeError{Addr: addr, Op: "connect sentinel", Err: err}
 	}
 	return rc, nil
 }
 
-//InitClusterConn 初始化集群连接
+// InitClusterConn 初始化集群连接
 func InitClusterConn(addrSlice []string, password string) (*redis.ClusterClient, error) {
-	rc := redis.NewClusterClient(&redis.ClusterOptions{
-		Addrs:    addrSlice,
+	return InitClusterConnWithOptions(addrSlice, &ConnOptions{
 		Password: password,
 		PoolSize: 1000,
 	})
+}
+
+// InitClusterConnWithOptions 通过连接选项初始化集群连接,集群不支持 DB 选项
+func InitClusterConnWithOptions(addrSlice []string, opts *ConnOptions) (*redis.ClusterClient, error) {
+	return initClusterConn(context.Background(), addrSlice, opts)
+}
+
+// initClusterConn 初始化集群连接, ctx 用于取消 PING 校验
+func initClusterConn(ctx context.Context, addrSlice []string, opts *ConnOptions) (*redis.ClusterClient, error) {
+	opts = connOptions(opts)
+	clusterOpts, err := opts.clusterOptions(addrSlice)
+	if err != nil {
+		return nil, err
+	}
+	rc := redis.NewClusterClient(clusterOpts)
+	rc.AddHook(commandHook{addr: strings.Join(addrSlice, ","), opts: opts})
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	_, err := rc.Ping(ctx).Result()
+	_, err = rc.Ping(ctx).Result()
 	if err != nil {
-		errMsg := fmt.Sprintf("集群节点: %s 连接失败: %v\n", addrSlice, err)
-		return nil, errors.New(errMsg)
+		rc.Close()
+		return nil, &NodeError{Addr: strings.Join(addrSlice, ","), Op: "connect cluster", Err: err}
 	}
 	return rc, nil
 }
@@ -0,0 +1,167 @@
+package redis
+
+import (
+	"context"
+	"path"
+	"sort"
+
+	"github.com/macoli/gowrapper/table"
+)
+
+// ==================================cluster config drift==================================================
+
+// DefaultDriftIgnore 与节点自身相关的配置项,各节点的值本来就不同,默认不参与一致性比较,支持通配符
+var DefaultDriftIgnore = []string{
+	"port",
+	"tls-port",
+	"bind",
+	"dir",
+	"dbfilename",
+	"logfile",
+	"pidfile",
+	"unixsocket",
+	"syslog-ident",
+	"appendfilename",
+	"appenddirname",
+	"replicaof",
+	"slaveof",
+	"cluster-config-file",
+	"cluster-announce-*",
+}
+
+// ConfigDriftItem 单个配置项在各节点上的值
+type ConfigDriftItem struct {
+	Param  string            // 配置项名称
+	Values map[string]string // 各节点上的值 {addr: value},节点不支持该配置项时没有对应的 key,密码类配置项的值以 xxxxx 代替
+	Drift  bool              // 各节点的值是否不一致(按实际值比较)
+}
+
+// ConfigDriftReport 集群所有节点的配置一致性报告
+type ConfigDriftReport struct {
+	Nodes  []string           // 参与比较的节点
+	Params []*ConfigDriftItem // 按配置项名称排序
+}
+
+// ConfigDrift 并发获取所有节点的全部配置(CONFIG GET *),生成每个配置项在各节点上的取值矩阵,
+// ignore 中的配置项不参与比较,为 nil 时使用 DefaultDriftIgnore
+func ConfigDrift(addrSlice []string, password string, ignore []string) (*ConfigDriftReport, error) {
+	return ConfigDriftContext(context.Background(), addrSlice, password, ignore)
+}
+
+// ConfigDriftContext 并发获取所有节点的全部配置并生成取值矩阵, ctx 取消时立即返回
+func ConfigDriftContext(ctx context.Context, addrSlice []string, password string, ignore []string) (*ConfigDriftReport, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ConfigDrift(ctx, addrSlice, ignore)
+}
+
+// ConfigDrift 通过连接池并发获取所有节点的全部配置,生成配置一致性报告, ignore 为 nil 时使用 DefaultDriftIgnore
+func (p *NodePool) ConfigDrift(ctx context.Context, addrSlice []string, ignore []string) (_ *ConfigDriftReport, err error) {
+	defer logOperation(p.logger(), "ConfigDrift", "nodes", len(addrSlice))(&err)
+
+	if ignore == nil {
+		ignore = DefaultDriftIgnore
+	}
+
+	// 并发获取每个节点的配置
+	configs := make([]map[string]string, len(addrSlice))
+	_, err = eachNode(addrSlice, true, func(i int, addr string) (err error) {
+		configs[i], err = p.configGetAll(ctx, addr, "*")
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 按配置项汇总各节点的值
+	items := make(map[string]*ConfigDriftItem)
+	for i, addr := range addrSlice {
+		for param, value := range configs[i] {
+			if driftIgnored(param, ignore) {
+				continue
+			}
+			item, ok := items[param]
+			if !ok {
+				item = &ConfigDriftItem{Param: param, Values: make(map[string]string)}
+				items[param] = item
+			}
+			item.Values[addr] = value
+		}
+	}
+
+	report := &ConfigDriftReport{Nodes: addrSlice}
+	for _, item := range items {
+		item.Drift = len(item.Values) != len(addrSlice) || !sameValues(item.Param, item.Values)
+		// 比较完成后再隐藏密码类配置项的值
+		for addr, value := range item.Values {
+			item.Values[addr] = redactSecret(item.Param, value)
+		}
+		report.Params = append(report.Params, item)
+	}
+	sort.Slice(report.Params, func(i, j int) bool {
+		return report.Params[i].Param < report.Params[j].Param
+	})
+	return report, nil
+}
+
+// driftIgnored 判断配置项是否在忽略列表中
+func driftIgnored(param string, ignore []string) bool {
+	for _, pattern := range ignore {
+		if ok, _ := path.Match(pattern, param); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sameValues 按配置项的值类型判断 map 中所有的值是否相同
+func sameValues(param string, values map[string]string) bool {
+	first, seen := "", false
+	for _, v := range values {
+		if !seen {
+			first, seen = v, true
+			continue
+		}
+		if !ConfigValueEqual(param, first, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Drifted 返回各节点取值不一致的配置项
+func (r *ConfigDriftReport) Drifted() (items []*ConfigDriftItem) {
+	for _, item := range r.Params {
+		if item.Drift {
+			items = append(items, item)
+		}
+	}
+	return
+}
+
+// ShowTable 通过表格展示配置一致性报告,不一致的配置项在 DRIFT 列中以 * 标记, onlyDrift 为 true 时只展示不一致的配置项
+func (r *ConfigDriftReport) ShowTable(onlyDrift bool) {
+	headers := append([]string{"PARAM", "DRIFT"}, r.Nodes...)
+
+	var rows []interface{}
+	for _, item := range r.Params {
+		if onlyDrift && !item.Drift {
+			continue
+		}
+		mark := ""
+		if item.Drift {
+			mark = "*"
+		}
+		row := []string{item.Param, mark}
+		for _, addr := range r.Nodes {
+			value, ok := item.Values[addr]
+			if !ok {
+				value = "-"
+			}
+			row = append(row, value)
+		}
+		rows = append(rows, row)
+	}
+
+	table.ShowTable(table.GenHeaderCellsByNames(headers), table.GenBodyCells(rows))
+}
@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConfigDriftRedactsSecrets(t *testing.T) {
+	configs := []map[string]string{
+		{"requirepass": "secret-a", "masterauth": "same", "maxmemory": "1gb", "port": "6379"},
+		{"requirepass": "secret-b", "masterauth": "same", "maxmemory": "1073741824", "port": "6380"},
+		{"requirepass": "", "masterauth": "same", "maxmemory": "1gb", "port": "6381"},
+	}
+	addrs := make([]string, len(configs))
+	for i := range configs {
+		config := configs[i]
+		addrs[i] = fakeRedis(t, func(args []string) interface{} {
+			if strings.ToLower(args[0]) != "config" || args[2] != "*" {
+				return errors.New("ERR unknown command '" + args[0] + "'")
+			}
+			var kv []string
+			for k, v := range config {
+				kv = append(kv, k, v)
+			}
+			return fakeFields(kv...)
+		})
+	}
+
+	p := NewNodePool(nil)
+	defer p.Close()
+	report, err := p.ConfigDrift(context.Background(), addrs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"masterauth":  {"xxxxx", "xxxxx", "xxxxx"},
+		"maxmemory":   {"1gb", "1073741824", "1gb"},
+		"requirepass": {"xxxxx", "xxxxx", ""},
+	}
+	drift := map[string]bool{"masterauth": false, "maxmemory": false, "requirepass": true}
+	if len(report.Params) != len(want) {
+		t.Fatalf("Params = %d items, want %d", len(report.Params), len(want))
+	}
+	for _, item := range report.Params {
+		for i, addr := range addrs {
+			if got := item.Values[addr]; got != want[item.Param][i] {
+				t.Errorf("%s on %s = %q, want %q", item.Param, addr, got, want[item.Param][i])
+			}
+		}
+		if item.Drift != drift[item.Param] {
+			t.Errorf("%s Drift = %v, want %v", item.Param, item.Drift, drift[item.Param])
+		}
+	}
+}
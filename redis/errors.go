@@ -0,0 +1,277 @@
+package redis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ==================================errors==================================================
+
+// Lang 错误信息使用的语言
+type Lang int
+
+const (
+	LangZH Lang = iota // 中文(默认)
+	LangEN             // 英文
+)
+
+// ErrorLang 错误信息使用的语言,应在程序初始化时设置,不要并发修改
+var ErrorLang = LangZH
+
+// errorMessages 错误信息目录 {key: [中文, 英文]}
+var errorMessages = map[string][2]string{
+	"node":               {"redis 节点 %s 执行 %s 失败, err:%v", "redis node %s: %s failed: %v"},
+	"config_mismatch":    {"集群配置项 %s 的值不一致: %s", "cluster config %s differs between nodes: %s"},
+	"config_not_applied": {"集群配置项 %s 设置后的值与设置的值 %s 不一致: %s", "cluster config %s was not applied as %s: %s"},
+	"slot_migration":     {"迁移 slot %d(%s -> %s)在 %s 阶段失败, err:%v", "migrating slot %d (%s -> %s) failed in %s phase: %v"},
+	"rollback":           {"%v, 回滚失败: %v", "%v, rollback failed: %v"},
+	"slowlog_gap":        {"redis 节点 %s 的慢查询日志 ID %d-%d 在两次获取之间被覆盖或重置, 已丢失", "redis node %s: slowlog entries %d-%d were overwritten or reset between polls and are lost"},
+	"pool_closed":        {"连接池已关闭", "node pool is closed"},
+	"pool_required":      {"没有指定连接池", "node pool is required"},
+	"config_unsupported": {"不支持的配置项", "unsupported config parameter"},
+	"confirm_required":   {"危险操作需要提供确认令牌, 可先通过演练模式获取令牌", "confirm token is required for destructive operations, run in dry-run mode to get it"},
+	"confirm_mismatch":   {"确认令牌与目标集群令牌不匹配, 请确认操作的集群是否正确", "confirm token does not match the target cluster, check the cluster being operated"},
+	"parse":              {"解析 %[1]s 失败: %[2]q, err:%[3]v", "failed to parse %[1]s %[2]q: %[3]v"},
+	"parse_node":         {"解析 redis 节点 %[1]s 的 %[2]s 失败: %[3]q, err:%[4]v", "failed to parse %[2]s from redis node %[1]s %[3]q: %[4]v"},
+	"file":               {"处理文件 %s 失败, err:%v", "file %s: %v"},
+	"file_line":          {"解析文件 %s 第 %d 行失败, err:%v", "file %s line %d: %v"},
+	"uri":                {"解析 redis URI %s 失败, err:%v", "failed to parse redis URI %s: %v"},
+	"arg":                {"参数 %[1]s 的值 %[2]q 不正确, err:%[3]v", "invalid value %[2]q for %[1]s: %[3]v"},
+	"invalid_format":     {"格式不正确", "invalid format"},
+	"unsupported":        {"不支持", "not supported"},
+	"include_loop":       {"配置文件被循环 include", "config file is included recursively"},
+	"invalid_include":    {"include 指令只能有一个参数", "include directive requires exactly one argument"},
+	"unclosed_quote":     {"引号未闭合", "unbalanced quotes"},
+	"quote_not_spaced":   {"引号闭合后必须跟空白", "closing quote must be followed by a space"},
+	"no_ca_cert":         {"没有可用的 CA 证书", "no CA certificate found"},
+	"uri_no_scheme":      {"缺少 scheme", "missing scheme"},
+	"uri_no_addr":        {"没有节点地址", "no node address"},
+	"uri_too_many_addrs": {"单实例模式只能有一个地址", "standalone mode accepts exactly one address"},
+	"uri_no_master":      {"缺少哨兵的 master name", "missing sentinel master name"},
+	"sentinel_only":      {"只能用于哨兵模式", "only allowed in sentinel mode"},
+	"no_sentinel":        {"没有可以连接的哨兵", "no sentinel available"},
+	"not_monitored":      {"哨兵没有监控该 master", "master is not monitored by the sentinels"},
+	"no_instance":        {"客户端连接没有所在节点的地址", "client has no instance address"},
+	"filter_required":    {"CLIENT KILL 至少需要一个过滤条件", "CLIENT KILL requires at least one filter"},
+	"lfu_required":       {"maxmemory-policy 不是 LFU 策略, 无法通过 OBJECT FREQ 获取访问频率", "maxmemory-policy is not an LFU policy, OBJECT FREQ is unavailable"},
+	"master_unmonitored": {"哨兵没有监控 master %s", "sentinel does not monitor master %s"},
+	"node_flagged":       {"%s %s 被标记为 %s", "%s %s is flagged as %s"},
+	"sentinel_count":     {"哨兵记录的哨兵数量为 %d, 发现的监控该 master 的哨兵数量为 %d", "sentinel knows %d sentinels, but %d sentinels monitoring the master were found"},
+	"quorum_too_large":   {"quorum %d 大于哨兵数量 %d", "quorum %d is greater than the number of sentinels %d"},
+	"master_mismatch":    {"哨兵之间 master 地址不一致: %s", "master address differs between sentinels: %s"},
+	"epoch_mismatch":     {"哨兵之间 config-epoch 不一致: %s", "config-epoch differs between sentinels: %s"},
+	"quorum_mismatch":    {"哨兵之间 quorum 配置不一致: %s", "quorum differs between sentinels: %s"},
+	"replicas_mismatch":  {"哨兵之间 slave 列表不一致: %s", "slave list differs between sentinels: %s"},
+}
+
+// message 按 ErrorLang 从错误信息目录中获取并格式化错误信息
+func message(key string, args ...interface{}) string {
+	m, ok := errorMessages[key]
+	if !ok {
+		return key
+	}
+	format := m[0]
+	if ErrorLang == LangEN {
+		format = m[1]
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// catalogError 错误信息来自错误信息目录的固定错误,可以直接用 == 或 errors.Is 比较
+type catalogError string
+
+func (e catalogError) Error() string {
+	return message(string(e))
+}
+
+// 固定错误
+var (
+	ErrPoolClosed        error = catalogError("pool_closed")        // 连接池已关闭
+	ErrPoolRequired      error = catalogError("pool_required")      // 没有指定连接池
+	ErrConfigUnsupported error = catalogError("config_unsupported") // 节点不支持配置项
+	ErrConfirmRequired   error = catalogError("confirm_required")   // 危险操作没有提供确认令牌
+	ErrConfirmMismatch   error = catalogError("confirm_mismatch")   // 确认令牌与目标集群不匹配
+	ErrInvalidFormat     error = catalogError("invalid_format")     // 内容格式不正确
+	ErrUnsupported       error = catalogError("unsupported")        // 不支持的参数或值
+	ErrIncludeLoop       error = catalogError("include_loop")       // 配置文件被循环 include
+	ErrInvalidInclude    error = catalogError("invalid_include")    // include 指令参数错误
+	ErrUnclosedQuote     error = catalogError("unclosed_quote")     // 配置行的引号未闭合
+	ErrQuoteNotSpaced    error = catalogError("quote_not_spaced")   // 配置行的引号闭合后没有跟空白
+	ErrNoCACert          error = catalogError("no_ca_cert")         // CA 证书文件中没有可用的证书
+	ErrURINoScheme       error = catalogError("uri_no_scheme")      // redis URI 缺少 scheme
+	ErrURINoAddr         error = catalogError("uri_no_addr")        // redis URI 没有节点地址
+	ErrURITooManyAddrs   error = catalogError("uri_too_many_addrs") // 单实例 redis URI 有多个地址
+	ErrURINoMasterName   error = catalogError("uri_no_master")      // 哨兵 redis URI 缺少 master name
+	ErrSentinelOnly      error = catalogError("sentinel_only")      // 参数只能用于哨兵模式
+	ErrNoSentinel        error = catalogError("no_sentinel")        // 没有指定哨兵地址或没有可以连接的哨兵
+	ErrNotMonitored      error = catalogError("not_monitored")      // 哨兵没有监控 master name
+	ErrNoInstance        error = catalogError("no_instance")        // 客户端连接没有所在节点的地址(Client.Instance)
+	ErrFilterRequired    error = catalogError("filter_required")    // CLIENT KILL 没有过滤条件
+	ErrLFURequired       error = catalogError("lfu_required")       // 节点的 maxmemory-policy 不是 LFU 策略
+)
+
+// NodeError 在节点上执行操作失败,Err 为底层错误(通常为 go-redis 返回的错误)
+type NodeError struct {
+	Addr string // 节点地址,哨兵和集群连接时为逗号分隔的多个地址
+	Op   string // 执行的操作,如 "connect"、"CONFIG SET maxmemory"
+	Err  error
+}
+
+func (e *NodeError) Error() string {
+	return message("node", e.Addr, e.Op, e.Err)
+}
+
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError 解析 redis 返回的结果或配置的值失败, Err 为具体原因
+type ParseError struct {
+	Addr  string // 返回结果的节点地址, 不是来自节点时为空
+	Kind  string // 解析的内容,如 "cluster nodes ping-sent"、"CLIENT LIST"、"redis_version"
+	Input string // 解析失败的内容
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	if e.Addr != "" {
+		return message("parse_node", e.Addr, e.Kind, e.Input, e.Err)
+	}
+	return message("parse", e.Kind, e.Input, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// FileError 读取或解析文件(配置文件、证书)失败, Line 为 0 时表示不是某一行的错误
+type FileError struct {
+	Path string
+	Line int
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	if e.Line > 0 {
+		return message("file_line", e.Path, e.Line, e.Err)
+	}
+	return message("file", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// URIError 解析 redis URI 失败, URI 中的密码已隐藏
+type URIError struct {
+	URI string
+	Err error
+}
+
+func (e *URIError) Error() string {
+	return message("uri", e.URI, e.Err)
+}
+
+func (e *URIError) Unwrap() error {
+	return e.Err
+}
+
+// ArgError 参数的值不正确或不支持, Err 为具体原因
+type ArgError struct {
+	Name  string
+	Value string
+	Err   error
+}
+
+func (e *ArgError) Error() string {
+	return message("arg", e.Name, e.Value, e.Err)
+}
+
+func (e *ArgError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigMismatchError 集群节点的配置项的值不一致
+/*
+1.一致性校验时 Expected 为空, Values 为已获取到的节点的值
+2.设置后校验时 Expected 为设置的值, Values 为未生效的节点读回的值
+*/
+type ConfigMismatchError struct {
+	Key      string
+	Expected string
+	Values   map[string]string // {addr: value}
+}
+
+func (e *ConfigMismatchError) Error() string {
+	values := formatNodeValues(e.Values)
+	if e.Expected != "" {
+		return message("config_not_applied", e.Key, e.Expected, values)
+	}
+	return message("config_mismatch", e.Key, values)
+}
+
+// formatNodeValues 按地址排序格式化每个节点的值: addr1=value1, addr2=value2
+func formatNodeValues(values map[string]string) string {
+	addrs := make([]string, 0, len(values))
+	for addr := range values {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	items := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		items = append(items, fmt.Sprintf("%s=%q", addr, values[addr]))
+	}
+	return strings.Join(items, ", ")
+}
+
+// slot 迁移的阶段
+const (
+	SlotPhaseImporting = "importing" // 目标节点 cluster setslot importing
+	SlotPhaseMigrating = "migrating" // 源节点 cluster setslot migrating
+	SlotPhaseGetKeys   = "getkeys"   // 源节点获取 slot 中的 key
+	SlotPhaseMigrate   = "migrate"   // 源节点 migrate 数据
+	SlotPhaseNode      = "node"      // 主节点 cluster setslot node
+)
+
+// SlotMigrationError 迁移 slot 失败, Phase 为失败的阶段, Err 通常为 *NodeError
+type SlotMigrationError struct {
+	Slot   int64
+	Phase  string
+	Source string // 源节点地址
+	Target string // 目标节点地址
+	Err    error
+}
+
+func (e *SlotMigrationError) Error() string {
+	return message("slot_migration", e.Slot, e.Source, e.Target, e.Phase, e.Err)
+}
+
+func (e *SlotMigrationError) Unwrap() error {
+	return e.Err
+}
+
+// RollbackError 操作失败后回滚也失败, Unwrap 返回操作失败的原因
+type RollbackError struct {
+	Err         error // 操作失败的原因
+	RollbackErr error // 回滚失败的原因
+}
+
+func (e *RollbackError) Error() string {
+	return message("rollback", e.Err, e.RollbackErr)
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// SlowLogGapError 持续获取慢查询日志时,两次获取之间有日志被覆盖(超过 slowlog-max-len)或被 SLOWLOG RESET 清空
+type SlowLogGapError struct {
+	Addr string
+	From int64 // 丢失的第一条日志 ID
+	To   int64 // 丢失的最后一条日志 ID
+}
+
+func (e *SlowLogGapError) Error() string {
+	return message("slowlog_gap", e.Addr, e.From, e.To)
+}
@@ -0,0 +1,261 @@
+package redis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ==================================destructive guard==================================================
+
+// Guard 危险操作(清空集群、修改集群配置、迁移 slot、哨兵管理、断开客户端连接)的安全防护
+type Guard struct {
+	DryRun  bool      // 演练模式: 只记录每个节点将要执行的命令,不真正执行,也不需要确认令牌
+	Confirm string    // 确认令牌,必须与执行前根据目标节点实时 ID 计算出的令牌一致才允许执行, 可通过 ClusterToken 或演练后的 Token 获取
+	Audit   io.Writer // 审计日志,不为 nil 时每条真正执行的危险命令都会写入一行记录
+
+	mu    sync.Mutex
+	token string              // 最近一次校验时计算出的目标集群令牌
+	addrs []string            // 演练模式下记录命令的节点顺序
+	plan  map[string][]string // 演练模式下记录的命令 {addr: [cmd, ...]}
+}
+
+// ClusterToken 根据集群所有节点(master 和 slave)的 ID 生成确认令牌
+func ClusterToken(data *ClusterInfo) string {
+	return nodeIDsToken(clusterNodeIDs(data))
+}
+
+// clusterNodeIDs 获取集群所有节点(master 和 slave)的 ID
+func clusterNodeIDs(data *ClusterInfo) (ids []string) {
+	for _, id := range data.AddrToID {
+		ids = append(ids, id)
+	}
+	return
+}
+
+// nodeIDsToken 对节点 ID 排序后计算 sha256,取前 12 位作为令牌
+func nodeIDsToken(ids []string) string {
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, ",")))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// verifyCluster 校验确认令牌, 令牌根据 addrSlice 中每个节点通过 CLUSTER MYID 实时获取的 ID 计算,
+// 调用方传入的 ClusterInfo 过期或与实际集群不符时令牌不匹配
+func (p *NodePool) verifyCluster(ctx context.Context, addrSlice []string, g *Guard) error {
+	if g == nil {
+		return nil
+	}
+	ids, err := p.clusterMyIDs(ctx, addrSlice)
+	if err != nil {
+		return err
+	}
+	return g.verify(ids)
+}
+
+// clusterMyIDs 通过 cluster myid 命令获取每个节点的 ID
+func (p *NodePool) clusterMyIDs(ctx context.Context, addrSlice []string) (ids []string, err error) {
+	for _, addr := range addrSlice {
+		id, err := p.clusterMyID(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return
+}
+
+// clusterMyID 获取单个节点的 ID
+func (p *NodePool) clusterMyID(ctx context.Context, addr string) (string, error) {
+	rc, release, err := p.acquire(ctx, addr)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var id string
+	err = p.retry(ctx, addr, "CLUSTER MYID", func() (err error) {
+		id, err = rc.Do(ctx, "cluster", "myid").Text()
+		return
+	})
+	if err != nil {
+		return "", &NodeError{Addr: addr, Op: "CLUSTER MYID", Err: err}
+	}
+	return id, nil
+}
+
+// runIDs 通过 INFO server 获取每个节点的 run_id
+func (p *NodePool) runIDs(ctx context.Context, addrSlice []string) (ids []string, err error) {
+	for _, addr := range addrSlice {
+		id, err := p.runID(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return
+}
+
+// runID 获取单个节点的 run_id, 节点重启后会变化
+func (p *NodePool) runID(ctx context.Context, addr string) (string, error) {
+	rc, release, err := p.acquire(ctx, addr)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var infoStr string
+	err = p.retry(ctx, addr, "INFO server", func() (err error) {
+		infoStr, err = rc.Info(ctx, "server").Result()
+		return
+	})
+	if err != nil {
+		return "", &NodeError{Addr: addr, Op: "INFO server", Err: err}
+	}
+	infoMap, err := InfoMap(infoStr)
+	if err != nil {
+		return "", err
+	}
+	return infoMap["run_id"], nil
+}
+
+// verify 校验确认令牌是否与目标集群节点 ID 匹配,演练模式下只记录令牌不做校验
+func (g *Guard) verify(ids []string) error {
+	if g == nil {
+		return nil
+	}
+	token := nodeIDsToken(ids)
+
+	g.mu.Lock()
+	g.token = token
+	g.mu.Unlock()
+
+	if g.DryRun {
+		return nil
+	}
+	if g.Confirm == "" {
+		return ErrConfirmRequired
+	}
+	if g.Confirm != token {
+		return ErrConfirmMismatch
+	}
+	return nil
+}
+
+// doer 可以执行任意命令的 redis 客户端
+type doer interface {
+	Do(ctx context.Context, args ...interface{}) *redis.Cmd
+}
+
+// do 在节点上执行危险命令: 演练模式下只记录命令,否则执行命令并写入审计日志
+func (g *Guard) do(ctx context.Context, rc doer, addr string, args ...interface{}) error {
+	if g != nil && g.DryRun {
+		g.record(addr, args...)
+		return nil
+	}
+
+	err := rc.Do(ctx, args...).Err()
+	if g != nil && g.Audit != nil {
+		g.mu.Lock()
+		result := "OK"
+		if err != nil {
+			result = err.Error()
+		}
+		fmt.Fprintf(g.Audit, "%s addr=%s token=%s cmd=%q result=%q\n",
+			time.Now().Format(time.RFC3339), addr, g.token, formatArgs(args), result)
+		g.mu.Unlock()
+	}
+	return err
+}
+
+// record 演练模式下记录节点将要执行的命令
+func (g *Guard) record(addr string, args ...interface{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.plan == nil {
+		g.plan = make(map[string][]string)
+	}
+	if _, ok := g.plan[addr]; !ok {
+		g.addrs = append(g.addrs, addr)
+	}
+	g.plan[addr] = append(g.plan[addr], formatArgs(args))
+}
+
+// Token 返回最近一次操作计算出的目标集群确认令牌,可在演练后用于 Confirm
+func (g *Guard) Token() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.token
+}
+
+// Plan 返回演练模式下记录的每个节点将要执行的命令 {addr: [cmd, ...]}
+func (g *Guard) Plan() map[string][]string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	plan := make(map[string][]string, len(g.plan))
+	for addr, cmds := range g.plan {
+		plan[addr] = append([]string(nil), cmds...)
+	}
+	return plan
+}
+
+// PlanString 按节点顺序格式化演练模式下记录的命令
+func (g *Guard) PlanString() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var b strings.Builder
+	for _, addr := range g.addrs {
+		fmt.Fprintf(&b, "%s\n", addr)
+		for _, cmd := range g.plan[addr] {
+			fmt.Fprintf(&b, "\t%s\n", cmd)
+		}
+	}
+	return b.String()
+}
+
+// secretArgs 值为密码的参数,格式化时隐藏紧跟其后的值
+var secretArgs = map[string]bool{
+	"requirepass":       true,
+	"masterauth":        true,
+	"auth-pass":         true,
+	"tls-key-file-pass": true,
+}
+
+// formatArgs 将命令参数格式化为空格分隔的字符串,密码参数的值以 xxxxx 代替
+func formatArgs(args []interface{}) string {
+	strs := make([]string, 0, len(args))
+	for i, arg := range args {
+		if i > 0 && secretArgs[strings.ToLower(fmt.Sprint(args[i-1]))] {
+			strs = append(strs, "xxxxx")
+			continue
+		}
+		strs = append(strs, fmt.Sprint(arg))
+	}
+	return strings.Join(strs, " ")
+}
+
+// redactSecret 密码类配置项的值以 xxxxx 代替,未设置密码(空值)时原样返回
+func redactSecret(param, value string) string {
+	if value != "" && secretArgs[strings.ToLower(param)] {
+		return "xxxxx"
+	}
+	return value
+}
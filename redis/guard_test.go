@@ -0,0 +1,121 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestFormatArgs(t *testing.T) {
+	tests := []struct {
+		args []interface{}
+		want string
+	}{
+		{args: nil, want: ""},
+		{args: []interface{}{"flushall"}, want: "flushall"},
+		{args: []interface{}{"client", "kill", "id", int64(12)}, want: "client kill id 12"},
+		{args: []interface{}{"config", "set", "requirepass", "secret"}, want: "config set requirepass xxxxx"},
+		{args: []interface{}{"config", "set", "MASTERAUTH", "secret", "timeout", "300"}, want: "config set MASTERAUTH xxxxx timeout 300"},
+		{args: []interface{}{"sentinel", "set", "mymaster", "auth-pass", "secret"}, want: "sentinel set mymaster auth-pass xxxxx"},
+		{args: []interface{}{"requirepass"}, want: "requirepass"},
+	}
+	for _, tt := range tests {
+		if got := formatArgs(tt.args); got != tt.want {
+			t.Errorf("formatArgs(%v) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestNodeIDsToken(t *testing.T) {
+	ids := []string{"c0ffee", "beef", "f00d"}
+	token := nodeIDsToken(ids)
+	if len(token) != 12 {
+		t.Errorf("nodeIDsToken() = %q, want 12 characters", token)
+	}
+	if got := nodeIDsToken([]string{"f00d", "c0ffee", "beef"}); got != token {
+		t.Errorf("nodeIDsToken() of reordered IDs = %q, want %q", got, token)
+	}
+	if !reflect.DeepEqual(ids, []string{"c0ffee", "beef", "f00d"}) {
+		t.Errorf("nodeIDsToken() reordered its input to %v", ids)
+	}
+	if got := nodeIDsToken([]string{"c0ffee", "beef"}); got == token {
+		t.Errorf("nodeIDsToken() of different IDs = %q, want a different token", got)
+	}
+}
+
+func TestGuardVerify(t *testing.T) {
+	ids := []string{"a", "b"}
+	token := nodeIDsToken(ids)
+	tests := []struct {
+		name string
+		g    *Guard
+		err  error
+	}{
+		{name: "nil guard", g: nil},
+		{name: "dry run", g: &Guard{DryRun: true}},
+		{name: "no confirm", g: &Guard{}, err: ErrConfirmRequired},
+		{name: "mismatch", g: &Guard{Confirm: "000000000000"}, err: ErrConfirmMismatch},
+		{name: "match", g: &Guard{Confirm: token}},
+	}
+	for _, tt := range tests {
+		if err := tt.g.verify(ids); err != tt.err {
+			t.Errorf("%s: verify() = %v, want %v", tt.name, err, tt.err)
+		}
+		if tt.g != nil && tt.g.Token() != token {
+			t.Errorf("%s: Token() = %q, want %q", tt.name, tt.g.Token(), token)
+		}
+	}
+}
+
+// fakeDoer 记录执行的命令, 总是返回 OK
+type fakeDoer struct {
+	cmds [][]interface{}
+}
+
+func (d *fakeDoer) Do(ctx context.Context, args ...interface{}) *redis.Cmd {
+	d.cmds = append(d.cmds, args)
+	return redis.NewCmdResult("OK", nil)
+}
+
+func TestGuardPlanRedactsSecrets(t *testing.T) {
+	rc := &fakeDoer{}
+	g := &Guard{DryRun: true}
+	_ = g.do(context.Background(), rc, "b:6379", "config", "set", "requirepass", "secret")
+	_ = g.do(context.Background(), rc, "a:6379", "config", "set", "masterauth", "secret")
+	_ = g.do(context.Background(), rc, "b:6379", "config", "rewrite")
+	if len(rc.cmds) != 0 {
+		t.Errorf("dry run executed %v", rc.cmds)
+	}
+
+	want := map[string][]string{
+		"b:6379": {"config set requirepass xxxxx", "config rewrite"},
+		"a:6379": {"config set masterauth xxxxx"},
+	}
+	if got := g.Plan(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Plan() = %v, want %v", got, want)
+	}
+	wantStr := "b:6379\n\tconfig set requirepass xxxxx\n\tconfig rewrite\na:6379\n\tconfig set masterauth xxxxx\n"
+	if got := g.PlanString(); got != wantStr {
+		t.Errorf("PlanString() = %q, want %q", got, wantStr)
+	}
+}
+
+func TestGuardAuditRedactsSecrets(t *testing.T) {
+	rc := &fakeDoer{}
+	var audit bytes.Buffer
+	g := &Guard{Audit: &audit}
+	if err := g.do(context.Background(), rc, "a:6379", "config", "set", "requirepass", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rc.cmds) != 1 {
+		t.Fatalf("executed %v, want 1 command", rc.cmds)
+	}
+	line := audit.String()
+	if strings.Contains(line, "secret") || !strings.Contains(line, `cmd="config set requirepass xxxxx" result="OK"`) {
+		t.Errorf("audit = %q, want redacted command", line)
+	}
+}
@@ -0,0 +1,406 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ==================================latency monitor==================================================
+
+// LatencyEvent LATENCY LATEST 返回的单个事件的最新延迟
+type LatencyEvent struct {
+	Instance string        // redis 实例地址
+	Event    string        // 事件名称, 如 command、fast-command、fork、aof-fsync-always
+	Time     time.Time     // 最近一次超过阈值的时间
+	Latest   time.Duration // 最近一次的延迟
+	Max      time.Duration // 历史最大延迟
+}
+
+// LatencySample LATENCY HISTORY 返回的单个延迟样本
+type LatencySample struct {
+	Time    time.Time
+	Latency time.Duration
+}
+
+// LatencyBucket 延迟直方图的桶, Count 为延迟小于等于 UpperBound 的累计调用次数
+type LatencyBucket struct {
+	UpperBound time.Duration
+	Count      int64
+}
+
+// LatencyHistogram LATENCY HISTOGRAM 返回的单个命令的延迟分布, redis 7 及以上版本才有
+type LatencyHistogram struct {
+	Command string
+	Calls   int64
+	Buckets []LatencyBucket // 按 UpperBound 从小到大排列
+}
+
+// Percentile 根据直方图估算分位数的延迟上界, q 为 0-1, 没有调用时返回 0
+func (h *LatencyHistogram) Percentile(q float64) time.Duration {
+	if len(h.Buckets) == 0 {
+		return 0
+	}
+	total := h.Buckets[len(h.Buckets)-1].Count
+	for _, bucket := range h.Buckets {
+		if float64(bucket.Count) >= q*float64(total) {
+			return bucket.UpperBound
+		}
+	}
+	return h.Buckets[len(h.Buckets)-1].UpperBound
+}
+
+// latencyReplyError 延迟命令返回的格式不正确
+func latencyReplyError(cmd string, reply interface{}) error {
+	return &ParseError{Kind: cmd, Input: fmt.Sprint(reply), Err: ErrInvalidFormat}
+}
+
+// ParseLatencyLatest 解析 LATENCY LATEST 的返回值: [[event, timestamp, latest_ms, max_ms], ...]
+func ParseLatencyLatest(reply interface{}) ([]*LatencyEvent, error) {
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, latencyReplyError("LATENCY LATEST", reply)
+	}
+	var events []*LatencyEvent
+	for _, item := range items {
+		fields, ok := item.([]interface{})
+		if !ok || len(fields) < 4 {
+			return nil, latencyReplyError("LATENCY LATEST", item)
+		}
+		event, ok1 := fields[0].(string)
+		ts, ok2 := fields[1].(int64)
+		latest, ok3 := fields[2].(int64)
+		max, ok4 := fields[3].(int64)
+		if !ok1 || !ok2 || !ok3 || !ok4 {
+			return nil, latencyReplyError("LATENCY LATEST", item)
+		}
+		events = append(events, &LatencyEvent{
+			Event:  event,
+			Time:   time.Unix(ts, 0),
+			Latest: time.Duration(latest) * time.Millisecond,
+			Max:    time.Duration(max) * time.Millisecond,
+		})
+	}
+	return events, nil
+}
+
+// ParseLatencyHistory 解析 LATENCY HISTORY 的返回值: [[timestamp, latency_ms], ...],按时间从早到晚排列
+func ParseLatencyHistory(reply interface{}) ([]LatencySample, error) {
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, latencyReplyError("LATENCY HISTORY", reply)
+	}
+	var samples []LatencySample
+	for _, item := range items {
+		fields, ok := item.([]interface{})
+		if !ok || len(fields) < 2 {
+			return nil, latencyReplyError("LATENCY HISTORY", item)
+		}
+		ts, ok1 := fields[0].(int64)
+		latency, ok2 := fields[1].(int64)
+		if !ok1 || !ok2 {
+			return nil, latencyReplyError("LATENCY HISTORY", item)
+		}
+		samples = append(samples, LatencySample{Time: time.Unix(ts, 0), Latency: time.Duration(latency) * time.Millisecond})
+	}
+	return samples, nil
+}
+
+// ParseLatencyHistogram 解析 LATENCY HISTOGRAM 的返回值:
+// [command, [calls, n, histogram_usec, [bucket_usec, count, ...]], ...],结果按命令名称排序
+func ParseLatencyHistogram(reply interface{}) ([]*LatencyHistogram, error) {
+	items, ok := reply.([]interface{})
+	if !ok || len(items)%2 != 0 {
+		return nil, latencyReplyError("LATENCY HISTOGRAM", reply)
+	}
+	var histograms []*LatencyHistogram
+	for i := 0; i < len(items); i += 2 {
+		command, ok1 := items[i].(string)
+		fields, ok2 := items[i+1].([]interface{})
+		if !ok1 || !ok2 {
+			return nil, latencyReplyError("LATENCY HISTOGRAM", items[i+1])
+		}
+		h := &LatencyHistogram{Command: command}
+		for j := 0; j+1 < len(fields); j += 2 {
+			name, _ := fields[j].(string)
+			switch name {
+			case "calls":
+				h.Calls, _ = fields[j+1].(int64)
+			case "histogram_usec":
+				buckets, ok := fields[j+1].([]interface{})
+				if !ok || len(buckets)%2 != 0 {
+					return nil, latencyReplyError("LATENCY HISTOGRAM", fields[j+1])
+				}
+				for k := 0; k < len(buckets); k += 2 {
+					bound, ok1 := buckets[k].(int64)
+					count, ok2 := buckets[k+1].(int64)
+					if !ok1 || !ok2 {
+						return nil, latencyReplyError("LATENCY HISTOGRAM", fields[j+1])
+					}
+					h.Buckets = append(h.Buckets, LatencyBucket{UpperBound: time.Duration(bound) * time.Microsecond, Count: count})
+				}
+			}
+		}
+		sort.Slice(h.Buckets, func(a, b int) bool { return h.Buckets[a].UpperBound < h.Buckets[b].UpperBound })
+		histograms = append(histograms, h)
+	}
+	sort.Slice(histograms, func(a, b int) bool { return histograms[a].Command < histograms[b].Command })
+	return histograms, nil
+}
+
+// latencyDo 在节点上执行 LATENCY 子命令,失败时按重试策略重试
+func (p *NodePool) latencyDo(ctx context.Context, addr string, args ...interface{}) (interface{}, error) {
+	rc, release, err := p.acquire(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	op := strings.ToUpper(formatArgs(args))
+	var reply interface{}
+	err = p.retry(ctx, addr, op, func() (err error) {
+		reply, err = rc.Do(ctx, args...).Result()
+		return
+	})
+	if err != nil {
+		return nil, &NodeError{Addr: addr, Op: op, Err: err}
+	}
+	return reply, nil
+}
+
+// LatencyLatest 获取节点所有事件的最新延迟(LATENCY LATEST)
+func (p *NodePool) LatencyLatest(ctx context.Context, addr string) ([]*LatencyEvent, error) {
+	reply, err := p.latencyDo(ctx, addr, "latency", "latest")
+	if err != nil {
+		return nil, err
+	}
+	events, err := ParseLatencyLatest(reply)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		event.Instance = addr
+	}
+	return events, nil
+}
+
+// LatencyHistory 获取节点上事件的延迟历史(LATENCY HISTORY), redis 最多保留最近 160 个样本
+func (p *NodePool) LatencyHistory(ctx context.Context, addr, event string) ([]LatencySample, error) {
+	reply, err := p.latencyDo(ctx, addr, "latency", "history", event)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLatencyHistory(reply)
+}
+
+// LatencyDoctor 获取节点的延迟分析报告(LATENCY DOCTOR)
+func (p *NodePool) LatencyDoctor(ctx context.Context, addr string) (string, error) {
+	reply, err := p.latencyDo(ctx, addr, "latency", "doctor")
+	if err != nil {
+		return "", err
+	}
+	report, ok := reply.(string)
+	if !ok {
+		return "", latencyReplyError("LATENCY DOCTOR", reply)
+	}
+	return report, nil
+}
+
+// LatencyHistogram 获取节点上命令的延迟分布(LATENCY HISTOGRAM), commands 为空时获取所有执行过的命令, redis 7 及以上版本才支持
+func (p *NodePool) LatencyHistogram(ctx context.Context, addr string, commands ...string) ([]*LatencyHistogram, error) {
+	args := []interface{}{"latency", "histogram"}
+	for _, command := range commands {
+		args = append(args, command)
+	}
+	reply, err := p.latencyDo(ctx, addr, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLatencyHistogram(reply)
+}
+
+// isUnknownCommand 判断错误是否为节点不支持该命令或子命令
+func isUnknownCommand(err error) bool {
+	var redisErr redis.Error
+	if !errors.As(err, &redisErr) {
+		return false
+	}
+	msg := strings.ToLower(redisErr.Error())
+	return strings.Contains(msg, "unknown subcommand") || strings.Contains(msg, "unknown command")
+}
+
+// ==================================cluster latency==================================================
+
+// NodeLatency 单个节点的延迟监控数据
+type NodeLatency struct {
+	Addr       string
+	Latest     []*LatencyEvent            // LATENCY LATEST
+	History    map[string][]LatencySample // 每个事件的延迟历史 {event: samples}
+	Doctor     string                     // LATENCY DOCTOR
+	Histograms []*LatencyHistogram        // LATENCY HISTOGRAM, redis 7 以下版本为空
+}
+
+// ClusterLatencyResult 多个节点的延迟监控数据
+type ClusterLatencyResult struct {
+	Nodes  []*NodeLatency   // 获取成功的节点,按地址排序
+	Errors map[string]error // 获取失败的节点 {addr: err}
+}
+
+// Events 获取所有节点的最新延迟事件,按最大延迟倒序排列
+func (r *ClusterLatencyResult) Events() []*LatencyEvent {
+	var events []*LatencyEvent
+	for _, node := range r.Nodes {
+		events = append(events, node.Latest...)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Max > events[j].Max })
+	return events
+}
+
+// ClusterLatency 并发获取所有节点的延迟监控数据
+func ClusterLatency(addrSlice []string, password string) (*ClusterLatencyResult, error) {
+	return ClusterLatencyContext(context.Background(), addrSlice, password)
+}
+
+// ClusterLatencyContext 并发获取所有节点的延迟监控数据, ctx 取消时立即返回
+func ClusterLatencyContext(ctx context.Context, addrSlice []string, password string) (*ClusterLatencyResult, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterLatency(ctx, addrSlice)
+}
+
+// ClusterLatency 通过连接池并发获取所有节点的延迟监控数据(LATEST、每个事件的 HISTORY、DOCTOR、HISTOGRAM),
+// 部分节点获取失败时返回其他节点的结果和失败节点的错误,所有节点都失败时返回错误
+func (p *NodePool) ClusterLatency(ctx context.Context, addrSlice []string) (ret *ClusterLatencyResult, err error) {
+	defer logOperation(p.logger(), "ClusterLatency", "nodes", len(addrSlice))(&err)
+
+	nodes := make([]*NodeLatency, len(addrSlice))
+	errs := make([]error, len(addrSlice))
+	eachNode(addrSlice, true, func(i int, addr string) error {
+		nodes[i], errs[i] = p.nodeLatency(ctx, addr)
+		return errs[i]
+	})
+
+	ret = &ClusterLatencyResult{Errors: make(map[string]error)}
+	for i, addr := range addrSlice {
+		if errs[i] != nil {
+			ret.Errors[addr] = errs[i]
+			continue
+		}
+		ret.Nodes = append(ret.Nodes, nodes[i])
+	}
+	if len(addrSlice) > 0 && len(ret.Errors) == len(addrSlice) {
+		return nil, errs[0]
+	}
+	sort.Slice(ret.Nodes, func(i, j int) bool { return ret.Nodes[i].Addr < ret.Nodes[j].Addr })
+	return ret, nil
+}
+
+// nodeLatency 获取单个节点的延迟监控数据
+func (p *NodePool) nodeLatency(ctx context.Context, addr string) (*NodeLatency, error) {
+	node := &NodeLatency{Addr: addr, History: make(map[string][]LatencySample)}
+	var err error
+	if node.Latest, err = p.LatencyLatest(ctx, addr); err != nil {
+		return nil, err
+	}
+	for _, event := range node.Latest {
+		if node.History[event.Event], err = p.LatencyHistory(ctx, addr, event.Event); err != nil {
+			return nil, err
+		}
+	}
+	if node.Doctor, err = p.LatencyDoctor(ctx, addr); err != nil {
+		return nil, err
+	}
+	node.Histograms, err = p.LatencyHistogram(ctx, addr)
+	if err != nil && !isUnknownCommand(err) {
+		return nil, err
+	}
+	return node, nil
+}
+
+// WithLatencyThreshold 临时将所有节点的 latency-monitor-threshold 设置为 threshold(毫秒精度)后执行 fn
+/*
+1.记录每个节点原来的阈值,通过 ClusterConfigSet 事务性地设置新阈值(任意节点失败时回滚)
+2.执行 fn
+3.无论 fn 是否成功,都将每个节点恢复为原来的阈值, ctx 可能已经取消,恢复使用新的 ctx
+4.fn 失败且恢复失败时返回 *RollbackError
+*/
+func (p *NodePool) WithLatencyThreshold(ctx context.Context, addrSlice []string, threshold time.Duration, fn func(ctx context.Context) error) (err error) {
+	const key = "latency-monitor-threshold"
+	ms := threshold.Milliseconds()
+	if threshold > 0 && ms == 0 {
+		ms = 1
+	}
+
+	// 记录每个节点原来的阈值
+	olds := make([]string, len(addrSlice))
+	_, err = eachNode(addrSlice, true, func(i int, addr string) (err error) {
+		olds[i], err = p.configGet(ctx, addr, key)
+		return
+	})
+	if err != nil {
+		return err
+	}
+
+	err = p.clusterConfigSet(ctx, addrSlice, map[string]string{key: strconv.FormatInt(ms, 10)}, &ConfigSetOptions{Parallel: true})
+	if err != nil {
+		return err
+	}
+
+	err = fn(ctx)
+
+	// 恢复原来的阈值
+	rbCtx := context.Background()
+	_, rbErr := eachNode(addrSlice, true, func(i int, addr string) error {
+		return p.configSet(rbCtx, addr, map[string]string{key: olds[i]}, nil)
+	})
+	if rbErr != nil {
+		p.logger().Warn("恢复 latency-monitor-threshold 失败", "err", rbErr)
+		if err != nil {
+			return &RollbackError{Err: err, RollbackErr: rbErr}
+		}
+		return rbErr
+	}
+	return err
+}
+
+// ClusterLatencyWatch 临时开启所有节点的延迟监控,等待 d 后获取延迟监控数据并恢复原来的阈值
+func ClusterLatencyWatch(addrSlice []string, password string, threshold, d time.Duration) (*ClusterLatencyResult, error) {
+	return ClusterLatencyWatchContext(context.Background(), addrSlice, password, threshold, d)
+}
+
+// ClusterLatencyWatchContext 临时开启所有节点的延迟监控,等待 d 后获取延迟监控数据并恢复原来的阈值,
+// ctx 取消时提前结束等待,详见 NodePool.ClusterLatencyWatch
+func ClusterLatencyWatchContext(ctx context.Context, addrSlice []string, password string, threshold, d time.Duration) (*ClusterLatencyResult, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterLatencyWatch(ctx, addrSlice, threshold, d)
+}
+
+// ClusterLatencyWatch 通过连接池临时将所有节点的 latency-monitor-threshold 设置为 threshold,
+// 等待 d 后获取延迟监控数据,最后恢复原来的阈值;
+// ctx 取消时提前结束等待,仍然使用新的 ctx 获取已经采集到的延迟监控数据并恢复阈值
+func (p *NodePool) ClusterLatencyWatch(ctx context.Context, addrSlice []string, threshold, d time.Duration) (ret *ClusterLatencyResult, err error) {
+	err = p.WithLatencyThreshold(ctx, addrSlice, threshold, func(ctx context.Context) (err error) {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			ctx = context.Background() // 提前结束时 ctx 已取消, 使用新的 ctx 获取数据
+		case <-timer.C:
+		}
+		ret, err = p.ClusterLatency(ctx, addrSlice)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,193 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseLatencyLatest(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply interface{}
+		want  []*LatencyEvent
+		err   error
+	}{
+		{name: "empty", reply: []interface{}{}, want: nil},
+		{
+			name: "events",
+			reply: []interface{}{
+				[]interface{}{"command", int64(1700000000), int64(120), int64(250)},
+				[]interface{}{"fork", int64(1700000100), int64(30), int64(30)},
+			},
+			want: []*LatencyEvent{
+				{Event: "command", Time: time.Unix(1700000000, 0), Latest: 120 * time.Millisecond, Max: 250 * time.Millisecond},
+				{Event: "fork", Time: time.Unix(1700000100, 0), Latest: 30 * time.Millisecond, Max: 30 * time.Millisecond},
+			},
+		},
+		{name: "not array", reply: "OK", err: ErrInvalidFormat},
+		{name: "short entry", reply: []interface{}{[]interface{}{"command", int64(1)}}, err: ErrInvalidFormat},
+		{name: "bad type", reply: []interface{}{[]interface{}{"command", "1", int64(1), int64(1)}}, err: ErrInvalidFormat},
+	}
+	for _, tt := range tests {
+		got, err := ParseLatencyLatest(tt.reply)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseLatencyLatest() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseLatencyHistory(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply interface{}
+		want  []LatencySample
+		err   error
+	}{
+		{
+			name:  "samples",
+			reply: []interface{}{[]interface{}{int64(1700000000), int64(5)}, []interface{}{int64(1700000010), int64(12)}},
+			want: []LatencySample{
+				{Time: time.Unix(1700000000, 0), Latency: 5 * time.Millisecond},
+				{Time: time.Unix(1700000010, 0), Latency: 12 * time.Millisecond},
+			},
+		},
+		{name: "not array", reply: nil, err: ErrInvalidFormat},
+		{name: "short entry", reply: []interface{}{[]interface{}{int64(1)}}, err: ErrInvalidFormat},
+		{name: "bad type", reply: []interface{}{[]interface{}{int64(1), "5"}}, err: ErrInvalidFormat},
+	}
+	for _, tt := range tests {
+		got, err := ParseLatencyHistory(tt.reply)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseLatencyHistory() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseLatencyHistogram(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply interface{}
+		want  []*LatencyHistogram
+		err   error
+	}{
+		{
+			name: "sorted by command and bound",
+			reply: []interface{}{
+				"set", []interface{}{"calls", int64(3), "histogram_usec", []interface{}{int64(4), int64(2), int64(2), int64(1), int64(8), int64(3)}},
+				"get", []interface{}{"calls", int64(1), "histogram_usec", []interface{}{int64(1), int64(1)}},
+			},
+			want: []*LatencyHistogram{
+				{Command: "get", Calls: 1, Buckets: []LatencyBucket{{UpperBound: time.Microsecond, Count: 1}}},
+				{Command: "set", Calls: 3, Buckets: []LatencyBucket{
+					{UpperBound: 2 * time.Microsecond, Count: 1},
+					{UpperBound: 4 * time.Microsecond, Count: 2},
+					{UpperBound: 8 * time.Microsecond, Count: 3},
+				}},
+			},
+		},
+		{name: "odd items", reply: []interface{}{"get"}, err: ErrInvalidFormat},
+		{name: "bad fields", reply: []interface{}{"get", "calls"}, err: ErrInvalidFormat},
+		{
+			name:  "odd buckets",
+			reply: []interface{}{"get", []interface{}{"histogram_usec", []interface{}{int64(1)}}},
+			err:   ErrInvalidFormat,
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseLatencyHistogram(tt.reply)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseLatencyHistogram() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLatencyHistogramPercentile(t *testing.T) {
+	h := &LatencyHistogram{Buckets: []LatencyBucket{
+		{UpperBound: 1 * time.Microsecond, Count: 50},
+		{UpperBound: 2 * time.Microsecond, Count: 90},
+		{UpperBound: 4 * time.Microsecond, Count: 99},
+		{UpperBound: 8 * time.Microsecond, Count: 100},
+	}}
+	tests := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{q: 0, want: time.Microsecond},
+		{q: 0.5, want: time.Microsecond},
+		{q: 0.9, want: 2 * time.Microsecond},
+		{q: 0.95, want: 4 * time.Microsecond},
+		{q: 0.99, want: 4 * time.Microsecond},
+		{q: 0.999, want: 8 * time.Microsecond},
+		{q: 1.5, want: 8 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		if got := h.Percentile(tt.q); got != tt.want {
+			t.Errorf("Percentile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+	if got := (&LatencyHistogram{}).Percentile(0.99); got != 0 {
+		t.Errorf("empty Percentile(0.99) = %v, want 0", got)
+	}
+}
+
+func TestClusterLatencyWatchCanceled(t *testing.T) {
+	var mu sync.Mutex
+	threshold := "0"
+	addr := fakeRedis(t, func(args []string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		switch strings.ToLower(args[0]) + " " + strings.ToLower(args[1]) {
+		case "config get":
+			return fakeFields("latency-monitor-threshold", threshold)
+		case "config set":
+			threshold = args[3]
+			return "OK"
+		case "latency latest":
+			return []interface{}{[]interface{}{[]byte("command"), int64(1700000000), int64(120), int64(250)}}
+		case "latency history":
+			return []interface{}{[]interface{}{int64(1700000000), int64(120)}}
+		case "latency doctor":
+			return []byte("Dave, no latency spike was observed.")
+		}
+		return errors.New("ERR unknown subcommand '" + args[1] + "'")
+	})
+
+	p := NewNodePool(nil)
+	defer p.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	ret, err := p.ClusterLatencyWatch(ctx, []string{addr}, 100*time.Millisecond, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("ClusterLatencyWatch() took %v after ctx was canceled", elapsed)
+	}
+	if len(ret.Nodes) != 1 || len(ret.Nodes[0].Latest) != 1 || len(ret.Nodes[0].History["command"]) != 1 {
+		t.Errorf("ClusterLatencyWatch() = %+v, want latency data of %s", ret, addr)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if threshold != "0" {
+		t.Errorf("latency-monitor-threshold = %s, want restored to 0", threshold)
+	}
+}
@@ -0,0 +1,131 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ==================================logger==================================================
+
+// Logger 日志接口, kv 为交替排列的 key、value,与 log/slog 的 Logger 方法一致
+/*
+1.Debug: 每个节点上执行的命令
+2.Info: 操作的开始、结束, slot 迁移进度
+3.Warn: 重试、健康检查失败
+4.Error: 操作失败
+*/
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+// nopLogger 不输出任何日志
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// loggerBox atomic.Value 要求每次存储的类型一致
+type loggerBox struct {
+	Logger
+}
+
+var defaultLogger atomic.Value
+
+// SetLogger 设置包级别的默认日志, ConnOptions.Logger 为空时使用, l 为 nil 时不输出日志(默认)
+func SetLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+	defaultLogger.Store(loggerBox{l})
+}
+
+// getLogger 获取包级别的默认日志
+func getLogger() Logger {
+	if box, ok := defaultLogger.Load().(loggerBox); ok {
+		return box.Logger
+	}
+	return nopLogger{}
+}
+
+// logger 获取连接选项的日志,没有设置时使用包级别的默认日志
+func (o *ConnOptions) logger() Logger {
+	if o != nil && o.Logger != nil {
+		return o.Logger
+	}
+	return getLogger()
+}
+
+// logOperation 记录操作开始,返回的函数在操作结束时记录耗时和错误,用法:
+// defer logOperation(l, "SlotMove", "source", addr)(&err)
+func logOperation(l Logger, op string, kv ...interface{}) func(errp *error) {
+	start := time.Now()
+	l.Info("操作开始", append([]interface{}{"op", op}, kv...)...)
+	return func(errp *error) {
+		fields := append([]interface{}{"op", op, "elapsed", time.Since(start)}, kv...)
+		if errp != nil && *errp != nil {
+			l.Error("操作失败", append(fields, "err", *errp)...)
+			return
+		}
+		l.Info("操作完成", fields...)
+	}
+}
+
+// commandHook 以 Debug 级别记录客户端执行的每个命令, 命令出错(redis.Nil 除外)时同时记录错误
+type commandHook struct {
+	addr string
+	opts *ConnOptions
+}
+
+type commandStartKey struct{}
+
+func (h commandHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, commandStartKey{}, time.Now()), nil
+}
+
+func (h commandHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.log(ctx, []redis.Cmder{cmd})
+	return nil
+}
+
+func (h commandHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, commandStartKey{}, time.Now()), nil
+}
+
+func (h commandHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	h.log(ctx, cmds)
+	return nil
+}
+
+func (h commandHook) log(ctx context.Context, cmds []redis.Cmder) {
+	l := h.opts.logger()
+	if _, ok := l.(nopLogger); ok {
+		return
+	}
+	var elapsed time.Duration
+	if start, ok := ctx.Value(commandStartKey{}).(time.Time); ok {
+		elapsed = time.Since(start)
+	}
+	for _, cmd := range cmds {
+		fields := []interface{}{"addr", h.addr, "cmd", strings.ToUpper(cmd.Name()), "elapsed", elapsed}
+		if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+			l.Debug("执行命令失败", append(fields, "err", err)...)
+			continue
+		}
+		l.Debug("执行命令", fields...)
+	}
+}
+
+// logger 获取连接池的日志
+func (p *NodePool) logger() Logger {
+	return p.Options.logger()
+}
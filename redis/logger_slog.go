@@ -0,0 +1,24 @@
+//go:build go1.21
+// +build go1.21
+
+package redis
+
+import "log/slog"
+
+// NewSlogLogger 将 *slog.Logger 适配为 Logger, l 为 nil 时使用 slog.Default()
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return slogLogger{l}
+}
+
+// slogLogger 通过 log/slog 输出日志, kv 作为 slog 的属性
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Debug(msg string, kv ...interface{}) { s.l.Debug(msg, kv...) }
+func (s slogLogger) Info(msg string, kv ...interface{})  { s.l.Info(msg, kv...) }
+func (s slogLogger) Warn(msg string, kv ...interface{})  { s.l.Warn(msg, kv...) }
+func (s slogLogger) Error(msg string, kv ...interface{}) { s.l.Error(msg, kv...) }
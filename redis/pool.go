@@ -0,0 +1,274 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ==================================node pool==================================================
+
+// NodePool 按节点地址缓存 redis 连接,同一个地址只建立一个客户端,所有节点使用同一份连接选项
+/*
+1.Get 获取节点的客户端并增加引用计数,用完后必须调用 Release 减少引用计数
+2.引用计数为 0 且空闲超过 IdleTimeout 的客户端会被关闭回收
+3.每隔 HealthCheckInterval 对空闲的客户端执行 PING,失败的客户端会被关闭回收,下次 Get 时重新建立
+4.Evict 移除的客户端不再被 Get 返回,仍被引用时在最后一次 Release 时关闭
+5.Close 后不能再 Get,仍被引用的客户端在最后一次 Release 时关闭
+6.零值 NodePool 可以直接使用,此时连接选项为零值选项,不做空闲回收和健康检查
+*/
+type NodePool struct {
+	Options             *ConnOptions  // 连接选项
+	IdleTimeout         time.Duration // 客户端空闲回收时间, 0 表示不回收
+	HealthCheckInterval time.Duration // 空闲客户端健康检查间隔, 0 表示不检查
+	Retry               *RetryPolicy  // 节点命令失败时的重试策略, nil 表示不重试,可以通过 WithRetry 为单次操作指定
+
+	mu      sync.Mutex
+	nodes   map[string]*poolNode
+	retired map[*redis.Client]*poolNode // 已被移除但仍被引用的客户端
+	closed  bool
+	once    sync.Once
+	stopped chan struct{}
+}
+
+// poolNode 节点客户端及其引用计数
+type poolNode struct {
+	client   *redis.Client
+	refs     int
+	lastUsed time.Time
+}
+
+// initLocked 初始化零值连接池的内部状态,调用时必须持有 p.mu
+func (p *NodePool) initLocked() {
+	if p.nodes == nil {
+		p.nodes = make(map[string]*poolNode)
+	}
+	if p.retired == nil {
+		p.retired = make(map[*redis.Client]*poolNode)
+	}
+	if p.stopped == nil {
+		p.stopped = make(chan struct{})
+	}
+}
+
+// NewNodePool 创建连接池,默认空闲 5 分钟回收,每 30 秒做一次健康检查
+func NewNodePool(opts *ConnOptions) *NodePool {
+	return &NodePool{
+		Options:             connOptions(opts),
+		IdleTimeout:         5 * time.Minute,
+		HealthCheckInterval: 30 * time.Second,
+		nodes:               make(map[string]*poolNode),
+		retired:             make(map[*redis.Client]*poolNode),
+		stopped:             make(chan struct{}),
+	}
+}
+
+// passwordPool 为只提供了密码的函数创建临时连接池,连接选项与 InitStandConn 一致
+func passwordPool(password string) *NodePool {
+	return NewNodePool(&ConnOptions{
+		Password:    password,
+		PoolSize:    100,
+		DialTimeout: time.Minute * 30,
+	})
+}
+
+// Get 获取节点的客户端,没有缓存时通过 ctx 建立连接,用完后必须调用 Release
+func (p *NodePool) Get(ctx context.Context, addr string) (*redis.Client, error) {
+	p.mu.Lock()
+	p.initLocked()
+	p.mu.Unlock()
+	p.once.Do(p.startJanitor)
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	if node, ok := p.nodes[addr]; ok {
+		node.refs++
+		node.lastUsed = time.Now()
+		p.mu.Unlock()
+		return node.client, nil
+	}
+	p.mu.Unlock()
+
+	// 建立连接时不持有锁,避免一个节点连接慢阻塞其他节点
+	rc, err := initStandConn(ctx, addr, p.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		rc.Close()
+		return nil, ErrPoolClosed
+	}
+	if node, ok := p.nodes[addr]; ok { // 并发 Get 时其他协程已经建立了连接
+		rc.Close()
+		node.refs++
+		node.lastUsed = time.Now()
+		return node.client, nil
+	}
+	p.nodes[addr] = &poolNode{client: rc, refs: 1, lastUsed: time.Now()}
+	return rc, nil
+}
+
+// Release 释放 Get 获取的节点客户端 rc
+func (p *NodePool) Release(addr string, rc *redis.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// 客户端已被 Evict 移除时只减少引用计数,最后一次 Release 时关闭
+	if node, ok := p.retired[rc]; ok {
+		node.refs--
+		if node.refs <= 0 {
+			delete(p.retired, rc)
+			node.client.Close()
+		}
+		return
+	}
+
+	node, ok := p.nodes[addr]
+	if !ok || node.client != rc || node.refs == 0 {
+		return
+	}
+	node.refs--
+	node.lastUsed = time.Now()
+}
+
+// acquire 获取节点客户端,返回的 release 函数用于释放
+func (p *NodePool) acquire(ctx context.Context, addr string) (*redis.Client, func(), error) {
+	var rc *redis.Client
+	err := p.retry(ctx, addr, "connect", func() (err error) {
+		rc, err = p.Get(ctx, addr)
+		return
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return rc, func() { p.Release(addr, rc) }, nil
+}
+
+// Evict 将节点的客户端从连接池移除,下次 Get 时重新建立,仍被引用时在最后一次 Release 时关闭
+func (p *NodePool) Evict(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.evictLocked(addr)
+}
+
+func (p *NodePool) evictLocked(addr string) {
+	node, ok := p.nodes[addr]
+	if !ok {
+		return
+	}
+	delete(p.nodes, addr)
+	if node.refs > 0 {
+		p.retired[node.client] = node
+		return
+	}
+	node.client.Close()
+}
+
+// Addrs 返回连接池中缓存的节点地址
+func (p *NodePool) Addrs() (addrs []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr := range p.nodes {
+		addrs = append(addrs, addr)
+	}
+	return
+}
+
+// HealthCheck 对所有空闲的客户端执行 PING,失败的客户端被移除并返回 {addr: err}
+func (p *NodePool) HealthCheck(ctx context.Context) map[string]error {
+	p.mu.Lock()
+	idle := make(map[string]*redis.Client)
+	for addr, node := range p.nodes {
+		if node.refs == 0 {
+			idle[addr] = node.client
+		}
+	}
+	p.mu.Unlock()
+
+	failed := make(map[string]error)
+	for addr, rc := range idle {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := rc.Ping(pingCtx).Err()
+		cancel()
+		if err != nil {
+			p.logger().Warn("健康检查失败, 移除节点客户端", "addr", addr, "err", err)
+			failed[addr] = err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr := range failed {
+		if node, ok := p.nodes[addr]; ok && node.client == idle[addr] {
+			p.evictLocked(addr)
+		}
+	}
+	return failed
+}
+
+// evictIdle 回收空闲超时的客户端
+func (p *NodePool) evictIdle() {
+	if p.IdleTimeout <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, node := range p.nodes {
+		if node.refs == 0 && time.Since(node.lastUsed) > p.IdleTimeout {
+			p.evictLocked(addr)
+		}
+	}
+}
+
+// startJanitor 启动后台协程,定期回收空闲客户端和做健康检查
+func (p *NodePool) startJanitor() {
+	interval := p.HealthCheckInterval
+	if p.IdleTimeout > 0 && (interval <= 0 || p.IdleTimeout < interval) {
+		interval = p.IdleTimeout
+	}
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		lastCheck := time.Now()
+		for {
+			select {
+			case <-p.stopped:
+				return
+			case <-ticker.C:
+				p.evictIdle()
+				if p.HealthCheckInterval > 0 && time.Since(lastCheck) >= p.HealthCheckInterval {
+					p.HealthCheck(context.Background())
+					lastCheck = time.Now()
+				}
+			}
+		}
+	}()
+}
+
+// Close 关闭连接池,空闲的客户端立即关闭,仍被引用的客户端在最后一次 Release 时关闭
+func (p *NodePool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.initLocked()
+	p.closed = true
+	close(p.stopped)
+	for addr := range p.nodes {
+		p.evictLocked(addr)
+	}
+	return nil
+}
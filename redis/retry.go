@@ -0,0 +1,156 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ==================================retry==================================================
+
+// RetryPolicy 节点命令失败时的重试策略
+/*
+1.只重试单个节点上的命令(连接、CONFIG GET/SET、CLUSTER SETSLOT 等),这些命令重复执行的结果基本不变,
+  但命令超时时可能已经在节点上执行成功,重试前需要确认命令可以重复执行, MIGRATE 等非幂等命令不重试
+2.第 n 次重试前等待 BaseDelay * Multiplier^(n-1),不超过 MaxDelay,再加上 ±Jitter 比例的随机抖动
+3.ctx 取消时立即停止重试并返回 ctx 的错误
+*/
+type RetryPolicy struct {
+	MaxAttempts int                  // 最大尝试次数(包括第一次), 小于等于 1 时不重试
+	BaseDelay   time.Duration        // 第一次重试前的等待时间
+	MaxDelay    time.Duration        // 等待时间上限, 0 表示不限制
+	Multiplier  float64              // 等待时间的增长倍数, 小于 1 时为 2
+	Jitter      float64              // 随机抖动比例, 0-1
+	Retryable   func(err error) bool // 判断错误是否可以重试, 为 nil 时使用 IsRetryable
+}
+
+// DefaultRetryPolicy 默认重试策略: 最多尝试 5 次,等待时间从 100ms 开始翻倍,最长 3s
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    3 * time.Second,
+	Multiplier:  2,
+	Jitter:      0.2,
+}
+
+// retryablePrefixes 可以重试的 redis 错误前缀
+var retryablePrefixes = map[string]bool{
+	"LOADING":     true, // 节点正在加载数据
+	"BUSY":        true, // 节点正在执行脚本
+	"TRYAGAIN":    true, // slot 迁移过程中多 key 命令的 key 不在同一节点
+	"CLUSTERDOWN": true, // 集群不可用
+	"MASTERDOWN":  true, // 与 master 断开连接
+}
+
+// IsRetryable 判断错误是否可以重试: 网络错误、连接断开,以及 LOADING、BUSY、TRYAGAIN、CLUSTERDOWN、MASTERDOWN 开头的 redis 错误
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, redis.Nil) || errors.Is(err, ErrPoolClosed) {
+		return false
+	}
+
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		prefix := strings.SplitN(redisErr.Error(), " ", 2)[0]
+		return retryablePrefixes[prefix]
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// Backoff 获取第 attempt 次重试前的等待时间, attempt 从 1 开始
+func (r *RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := r.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	delay := float64(r.BaseDelay) * math.Pow(multiplier, float64(attempt-1))
+	if r.MaxDelay > 0 && delay > float64(r.MaxDelay) {
+		delay = float64(r.MaxDelay)
+	}
+	if r.Jitter > 0 {
+		delay += delay * r.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// Do 按重试策略执行 fn, r 为 nil 时只执行一次,返回最后一次执行的错误
+func (r *RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	return r.do(ctx, fn, nil)
+}
+
+// do 按重试策略执行 fn,每次重试前调用 onRetry
+func (r *RetryPolicy) do(ctx context.Context, fn func() error, onRetry func(attempt int, delay time.Duration, err error)) error {
+	err := fn()
+	if r == nil {
+		return err
+	}
+	retryable := r.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	for attempt := 1; err != nil && attempt < r.MaxAttempts && retryable(err); attempt++ {
+		delay := r.Backoff(attempt)
+		if onRetry != nil {
+			onRetry(attempt, delay, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		err = fn()
+	}
+	return err
+}
+
+type retryKey struct{}
+
+// WithRetry 返回带重试策略的 ctx, 使用该 ctx 的操作按 r 重试,优先于 NodePool.Retry, r 为 nil 时不重试
+func WithRetry(ctx context.Context, r *RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryKey{}, r)
+}
+
+// retryPolicy 获取操作使用的重试策略: ctx 中的重试策略优先,其次为连接池的重试策略
+func (p *NodePool) retryPolicy(ctx context.Context) *RetryPolicy {
+	if r, ok := ctx.Value(retryKey{}).(*RetryPolicy); ok {
+		return r
+	}
+	return p.Retry
+}
+
+// retry 按重试策略在节点上执行 fn, 每次重试记录 Warn 日志
+func (p *NodePool) retry(ctx context.Context, addr, op string, fn func() error) error {
+	return p.retryPolicy(ctx).do(ctx, fn, func(attempt int, delay time.Duration, err error) {
+		p.logger().Warn("节点命令失败, 等待后重试", "addr", addr, "op", op, "attempt", attempt, "delay", delay, "err", err)
+	})
+}
+
+// do 在节点上执行命令,失败时按重试策略重试, g 不为 nil 时支持演练模式和审计日志
+func (p *NodePool) do(ctx context.Context, g *Guard, rc doer, addr string, args ...interface{}) error {
+	op := strings.ToUpper(formatArgs(args[:1]))
+	return p.retry(ctx, addr, op, func() error {
+		return g.do(ctx, rc, addr, args...)
+	})
+}
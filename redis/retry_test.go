@@ -0,0 +1,134 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// replyError 模拟 redis 节点返回的错误
+type replyError string
+
+func (e replyError) Error() string { return string(e) }
+
+func (replyError) RedisError() {}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "deadline", err: fmt.Errorf("wrap: %w", context.DeadlineExceeded), want: false},
+		{name: "redis nil", err: redis.Nil, want: false},
+		{name: "pool closed", err: ErrPoolClosed, want: false},
+		{name: "loading", err: replyError("LOADING Redis is loading the dataset in memory"), want: true},
+		{name: "busy", err: replyError("BUSY Redis is busy running a script"), want: true},
+		{name: "tryagain", err: replyError("TRYAGAIN Multiple keys request during rehashing of slot"), want: true},
+		{name: "clusterdown", err: replyError("CLUSTERDOWN The cluster is down"), want: true},
+		{name: "masterdown", err: replyError("MASTERDOWN Link with MASTER is down"), want: true},
+		{name: "wrapped redis error", err: &NodeError{Addr: "a", Op: "GET", Err: replyError("LOADING")}, want: true},
+		{name: "err", err: replyError("ERR unknown command"), want: false},
+		{name: "moved", err: replyError("MOVED 3999 127.0.0.1:6381"), want: false},
+		{name: "eof", err: io.EOF, want: true},
+		{name: "unexpected eof", err: io.ErrUnexpectedEOF, want: true},
+		{name: "conn reset", err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, want: true},
+		{name: "conn refused", err: syscall.ECONNREFUSED, want: true},
+		{name: "broken pipe", err: syscall.EPIPE, want: true},
+		{name: "other", err: errors.New("other"), want: false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{name: "first", policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 2}, attempt: 1, want: 100 * time.Millisecond},
+		{name: "third", policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 2}, attempt: 3, want: 400 * time.Millisecond},
+		{name: "multiplier 3", policy: RetryPolicy{BaseDelay: 10 * time.Millisecond, Multiplier: 3}, attempt: 3, want: 90 * time.Millisecond},
+		{name: "default multiplier", policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 0.5}, attempt: 2, want: 200 * time.Millisecond},
+		{name: "max delay", policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 2, MaxDelay: time.Second}, attempt: 10, want: time.Second},
+		{name: "no max delay", policy: RetryPolicy{BaseDelay: time.Millisecond, Multiplier: 2}, attempt: 11, want: 1024 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Backoff(tt.attempt); got != tt.want {
+			t.Errorf("%s: Backoff(%d) = %v, want %v", tt.name, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	r := &RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 2, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		got := r.Backoff(2)
+		if got < 160*time.Millisecond || got > 240*time.Millisecond {
+			t.Fatalf("Backoff(2) = %v, want 160ms-240ms", got)
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	retryable := replyError("LOADING")
+	tests := []struct {
+		name   string
+		policy *RetryPolicy
+		errs   []error // 每次执行返回的错误, 用完后返回 nil
+		calls  int
+		err    error
+	}{
+		{name: "nil policy", policy: nil, errs: []error{retryable}, calls: 1, err: retryable},
+		{name: "success after retry", policy: &RetryPolicy{MaxAttempts: 3}, errs: []error{retryable, retryable}, calls: 3},
+		{name: "max attempts", policy: &RetryPolicy{MaxAttempts: 2}, errs: []error{retryable, retryable, retryable}, calls: 2, err: retryable},
+		{name: "not retryable", policy: &RetryPolicy{MaxAttempts: 3}, errs: []error{io.ErrClosedPipe}, calls: 1, err: io.ErrClosedPipe},
+		{
+			name:   "custom retryable",
+			policy: &RetryPolicy{MaxAttempts: 3, Retryable: func(err error) bool { return err == io.ErrClosedPipe }},
+			errs:   []error{io.ErrClosedPipe},
+			calls:  2,
+		},
+	}
+	for _, tt := range tests {
+		calls := 0
+		err := tt.policy.Do(context.Background(), func() error {
+			calls++
+			if calls <= len(tt.errs) {
+				return tt.errs[calls-1]
+			}
+			return nil
+		})
+		if err != tt.err || calls != tt.calls {
+			t.Errorf("%s: Do() = %v after %d calls, want %v after %d calls", tt.name, err, calls, tt.err, tt.calls)
+		}
+	}
+}
+
+func TestRetryPolicyDoCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour}
+	calls := 0
+	err := r.Do(ctx, func() error {
+		calls++
+		cancel()
+		return io.EOF
+	})
+	if err != context.Canceled || calls != 1 {
+		t.Errorf("Do() = %v after %d calls, want %v after 1 call", err, calls, context.Canceled)
+	}
+}
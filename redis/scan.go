@@ -0,0 +1,202 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ==================================key scan==================================================
+
+// KeyIterator 通过 SCAN 遍历单个节点或集群所有 master 上的 key
+/*
+1.按 Addrs 的顺序逐个节点遍历,每个节点从记录的游标开始执行 SCAN,直到游标回到 0
+2.每批 key 处理成功后才推进游标,中断后可以通过 Cursors 保存游标、通过 Resume 恢复,恢复后可能重复处理最后一批 key
+3.SCAN 本身保证遍历开始时就存在且一直存在的 key 至少返回一次,遍历过程中新增、删除或迁移 slot 的 key 可能重复或遗漏
+4.Interval 大于 0 时每批之间等待 Interval,降低对节点的压力
+5.直接创建 KeyIterator 且没有调用 Resume 时,所有 Addrs 从游标 0 开始遍历
+*/
+type KeyIterator struct {
+	Pool     *NodePool
+	Addrs    []string
+	Match    string        // SCAN MATCH 模式, 为空时匹配所有 key
+	Count    int64         // SCAN COUNT, 每次扫描的数量提示, 小于等于 0 时使用 redis 默认值 10
+	Type     string        // SCAN TYPE, 只返回该类型的 key, redis 6 及以上版本才支持
+	Interval time.Duration // 每批之间的等待时间
+
+	mu      sync.Mutex
+	cursors map[string]uint64 // 未完成节点的游标 {addr: cursor}, nil 表示所有节点都未开始
+	err     error
+}
+
+// NewKeyIterator 创建遍历单个节点的 key 迭代器
+func NewKeyIterator(p *NodePool, addr string) *KeyIterator {
+	return newKeyIterator(p, []string{addr})
+}
+
+// NewClusterKeyIterator 创建遍历集群所有 master 的 key 迭代器
+func NewClusterKeyIterator(p *NodePool, data *ClusterInfo) *KeyIterator {
+	return newKeyIterator(p, clusterMasterAddrs(data))
+}
+
+func newKeyIterator(p *NodePool, addrs []string) *KeyIterator {
+	return &KeyIterator{Pool: p, Addrs: addrs}
+}
+
+// initCursorsLocked 游标未初始化时所有节点从游标 0 开始,调用时必须持有 it.mu
+func (it *KeyIterator) initCursorsLocked() {
+	if it.cursors != nil {
+		return
+	}
+	it.cursors = make(map[string]uint64, len(it.Addrs))
+	for _, addr := range it.Addrs {
+		it.cursors[addr] = 0
+	}
+}
+
+// Cursors 获取所有未完成节点的游标 {addr: cursor},未开始的节点游标为 0,已完成的节点不在其中
+func (it *KeyIterator) Cursors() map[string]uint64 {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.initCursorsLocked()
+	ret := make(map[string]uint64, len(it.cursors))
+	for addr, cursor := range it.cursors {
+		ret[addr] = cursor
+	}
+	return ret
+}
+
+// Resume 从保存的游标恢复遍历, 不在 cursors 中的节点视为已完成
+func (it *KeyIterator) Resume(cursors map[string]uint64) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.cursors = make(map[string]uint64, len(cursors))
+	for addr, cursor := range cursors {
+		it.cursors[addr] = cursor
+	}
+}
+
+// Done 判断是否所有节点都已遍历完成
+func (it *KeyIterator) Done() bool {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.initCursorsLocked()
+	return len(it.cursors) == 0
+}
+
+// Each 遍历所有未完成节点的 key,每批 key 调用一次 fn, fn 返回错误时停止遍历并返回该错误,
+// ctx 取消时停止遍历并返回 ctx 的错误
+func (it *KeyIterator) Each(ctx context.Context, fn func(addr string, keys []string) error) (err error) {
+	defer logOperation(it.Pool.logger(), "KeyScan", "nodes", len(it.Addrs), "match", it.Match)(&err)
+
+	it.mu.Lock()
+	it.initCursorsLocked()
+	it.mu.Unlock()
+
+	first := true
+	for _, addr := range it.Addrs {
+		it.mu.Lock()
+		cursor, ok := it.cursors[addr]
+		it.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		if err = it.scanNode(ctx, addr, cursor, &first, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanNode 从 cursor 开始遍历单个节点的 key
+func (it *KeyIterator) scanNode(ctx context.Context, addr string, cursor uint64, first *bool, fn func(addr string, keys []string) error) error {
+	rc, release, err := it.Pool.acquire(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	for {
+		// 每批之间等待
+		if !*first && it.Interval > 0 {
+			timer := time.NewTimer(it.Interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		*first = false
+
+		var keys []string
+		var next uint64
+		err = it.Pool.retry(ctx, addr, "SCAN", func() error {
+			scanCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			if it.Type != "" {
+				keys, next, err = rc.ScanType(scanCtx, cursor, it.Match, it.Count, it.Type).Result()
+			} else {
+				keys, next, err = rc.Scan(scanCtx, cursor, it.Match, it.Count).Result()
+			}
+			return err
+		})
+		if err != nil {
+			return &NodeError{Addr: addr, Op: "SCAN", Err: err}
+		}
+
+		if len(keys) > 0 {
+			if err = fn(addr, keys); err != nil {
+				return err
+			}
+		}
+
+		// 处理成功后推进游标
+		it.mu.Lock()
+		if next == 0 {
+			delete(it.cursors, addr)
+		} else {
+			it.cursors[addr] = next
+		}
+		it.mu.Unlock()
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Keys 在后台遍历所有未完成节点的 key,通过 channel 逐个投递, 遍历结束或 ctx 取消后关闭 channel,
+// channel 关闭后通过 Err 获取遍历的错误
+func (it *KeyIterator) Keys(ctx context.Context) <-chan string {
+	buffer := it.Count
+	if buffer < 0 {
+		buffer = 0
+	}
+	out := make(chan string, buffer)
+	go func() {
+		defer close(out)
+		err := it.Each(ctx, func(addr string, keys []string) error {
+			for _, key := range keys {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case out <- key:
+				}
+			}
+			return nil
+		})
+		it.mu.Lock()
+		it.err = err
+		it.mu.Unlock()
+	}()
+	return out
+}
+
+// Err 获取 Keys 遍历的错误, 应在 channel 关闭后调用
+func (it *KeyIterator) Err() error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.err
+}
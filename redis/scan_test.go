@@ -0,0 +1,218 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeScanNode 测试用的 redis 节点, SCAN 以 key 的下标为游标, 每次最多返回 2 个 key
+type fakeScanNode struct {
+	mu    sync.Mutex
+	keys  []string          // 按顺序遍历的 key
+	types map[string]string // key 的类型, 没有时为 string
+	scans [][]string        // 收到的 SCAN 命令
+}
+
+func (n *fakeScanNode) handle(args []string) interface{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if strings.ToLower(args[0]) != "scan" {
+		return errors.New("ERR unknown command '" + args[0] + "'")
+	}
+	n.scans = append(n.scans, args)
+
+	cursor, err := strconv.Atoi(args[1])
+	if err != nil {
+		return errors.New("ERR invalid cursor")
+	}
+	var match, typ string
+	for i := 2; i+1 < len(args); i += 2 {
+		switch strings.ToLower(args[i]) {
+		case "match":
+			match = args[i+1]
+		case "type":
+			typ = args[i+1]
+		}
+	}
+
+	var keys []interface{}
+	end := cursor + 2
+	if end >= len(n.keys) {
+		end = 0
+	}
+	batch := n.keys[cursor:]
+	if end > 0 {
+		batch = n.keys[cursor:end]
+	}
+	for _, key := range batch {
+		if ok, _ := path.Match(match, key); match != "" && !ok {
+			continue
+		}
+		if t := n.types[key]; typ != "" && typ != t && !(t == "" && typ == "string") {
+			continue
+		}
+		keys = append(keys, []byte(key))
+	}
+	if keys == nil {
+		keys = []interface{}{}
+	}
+	return []interface{}{[]byte(strconv.Itoa(end)), keys}
+}
+
+func TestKeyIteratorEach(t *testing.T) {
+	a := &fakeScanNode{keys: []string{"user:1", "order:1", "user:2", "user:3", "order:2"}, types: map[string]string{"user:3": "hash"}}
+	b := &fakeScanNode{keys: []string{"user:4"}}
+	addrA, addrB := fakeRedis(t, a.handle), fakeRedis(t, b.handle)
+
+	p := NewNodePool(nil)
+	defer p.Close()
+	tests := []struct {
+		name  string
+		match string
+		typ   string
+		want  map[string][]string
+		scans []string
+	}{
+		{
+			name: "all",
+			want: map[string][]string{addrA: {"user:1", "order:1", "user:2", "user:3", "order:2"}, addrB: {"user:4"}},
+			scans: []string{
+				"scan 0 count 100", "scan 2 count 100", "scan 4 count 100",
+				"scan 0 count 100",
+			},
+		},
+		{
+			name:  "match",
+			match: "user:*",
+			want:  map[string][]string{addrA: {"user:1", "user:2", "user:3"}, addrB: {"user:4"}},
+			scans: []string{
+				"scan 0 match user:* count 100", "scan 2 match user:* count 100", "scan 4 match user:* count 100",
+				"scan 0 match user:* count 100",
+			},
+		},
+		{
+			name:  "type",
+			match: "user:*",
+			typ:   "hash",
+			want:  map[string][]string{addrA: {"user:3"}},
+			scans: []string{
+				"scan 0 match user:* count 100 type hash", "scan 2 match user:* count 100 type hash", "scan 4 match user:* count 100 type hash",
+				"scan 0 match user:* count 100 type hash",
+			},
+		},
+	}
+	for _, tt := range tests {
+		a.scans, b.scans = nil, nil
+		it := newKeyIterator(p, []string{addrA, addrB})
+		it.Match, it.Count, it.Type = tt.match, 100, tt.typ
+
+		got := make(map[string][]string)
+		err := it.Each(context.Background(), func(addr string, keys []string) error {
+			got[addr] = append(got[addr], keys...)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: keys = %v, want %v", tt.name, got, tt.want)
+		}
+		var scans []string
+		for _, args := range append(a.scans, b.scans...) {
+			scans = append(scans, strings.ToLower(strings.Join(args, " ")))
+		}
+		if !reflect.DeepEqual(scans, tt.scans) {
+			t.Errorf("%s: scans = %q, want %q", tt.name, scans, tt.scans)
+		}
+		if !it.Done() || len(it.Cursors()) != 0 {
+			t.Errorf("%s: Done() = false, cursors %v", tt.name, it.Cursors())
+		}
+	}
+}
+
+func TestKeyIteratorResume(t *testing.T) {
+	a := &fakeScanNode{keys: []string{"k1", "k2", "k3", "k4", "k5"}}
+	b := &fakeScanNode{keys: []string{"k6"}}
+	addrA, addrB := fakeRedis(t, a.handle), fakeRedis(t, b.handle)
+
+	p := NewNodePool(nil)
+	defer p.Close()
+	it := newKeyIterator(p, []string{addrA, addrB})
+	if got := it.Cursors(); !reflect.DeepEqual(got, map[string]uint64{addrA: 0, addrB: 0}) {
+		t.Errorf("Cursors() before Each = %v, want all 0", got)
+	}
+
+	// 第二批处理失败时游标停在该批之前
+	errStop := errors.New("stop")
+	var seen []string
+	err := it.Each(context.Background(), func(addr string, keys []string) error {
+		if len(seen) == 2 {
+			return errStop
+		}
+		seen = append(seen, keys...)
+		return nil
+	})
+	if err != errStop {
+		t.Fatalf("Each() = %v, want %v", err, errStop)
+	}
+	cursors := it.Cursors()
+	if want := map[string]uint64{addrA: 2, addrB: 0}; !reflect.DeepEqual(cursors, want) {
+		t.Fatalf("Cursors() = %v, want %v", cursors, want)
+	}
+
+	// 从保存的游标恢复, 重新处理失败的一批
+	resumed := newKeyIterator(p, []string{addrA, addrB})
+	resumed.Resume(cursors)
+	for key := range resumed.Keys(context.Background()) {
+		seen = append(seen, key)
+	}
+	if err := resumed.Err(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(seen)
+	if want := []string{"k1", "k2", "k3", "k4", "k5", "k6"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("keys = %v, want %v", seen, want)
+	}
+	if !resumed.Done() {
+		t.Errorf("Done() = false after all nodes were scanned, cursors %v", resumed.Cursors())
+	}
+
+	// 不在保存的游标中的节点视为已完成
+	done := newKeyIterator(p, []string{addrA, addrB})
+	done.Resume(map[string]uint64{addrB: 0})
+	var keys []string
+	for key := range done.Keys(context.Background()) {
+		keys = append(keys, key)
+	}
+	if want := []string{"k6"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+}
+
+func TestKeyIteratorScanError(t *testing.T) {
+	addr := fakeRedis(t, func(args []string) interface{} {
+		return errors.New("ERR unknown type name 'bogus'")
+	})
+
+	p := NewNodePool(nil)
+	defer p.Close()
+	it := NewKeyIterator(p, addr)
+	it.Type = "bogus"
+	for range it.Keys(context.Background()) {
+		t.Error("Keys() delivered a key, want none")
+	}
+	var nodeErr *NodeError
+	if err := it.Err(); !errors.As(err, &nodeErr) || nodeErr.Addr != addr || nodeErr.Op != "SCAN" {
+		t.Errorf("Err() = %v, want SCAN error of %s", err, addr)
+	}
+	if got := it.Cursors(); !reflect.DeepEqual(got, map[string]uint64{addr: 0}) {
+		t.Errorf("Cursors() = %v, want %s at 0", got, addr)
+	}
+}
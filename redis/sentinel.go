@@ -0,0 +1,409 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ==================================sentinel topology==================================================
+
+// SentinelNode 哨兵返回的节点的公共信息
+type SentinelNode struct {
+	Name            string            // 节点名称, master 为 master name, 其他节点为 ip:port 或 runid
+	Addr            string            // 节点地址(ip:port)
+	RunID           string            // 节点的 run id
+	Flags           []string          // 节点标志: master, slave, sentinel, s_down, o_down, disconnected 等
+	LastOkPingReply time.Duration     // 距离最近一次收到正常 PING 回复的时间
+	Raw             map[string]string // 哨兵返回的所有字段
+}
+
+// HasFlag 判断节点是否有 flag 标志
+func (n *SentinelNode) HasFlag(flag string) bool {
+	for _, f := range n.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDown 判断节点是否被哨兵判断为主观下线、客观下线或已断开连接
+func (n *SentinelNode) IsDown() bool {
+	return n.HasFlag("s_down") || n.HasFlag("o_down") || n.HasFlag("disconnected")
+}
+
+// SentinelMaster SENTINEL MASTERS 返回的 master 信息
+type SentinelMaster struct {
+	SentinelNode
+	Quorum            int64         // 判断客观下线需要的哨兵数量
+	DownAfter         time.Duration // down-after-milliseconds, 判断主观下线的时间
+	FailoverTimeout   time.Duration // 故障转移超时时间
+	ParallelSyncs     int64         // 故障转移时同时向新 master 同步的 slave 数量
+	ConfigEpoch       int64         // 配置纪元
+	NumSlaves         int64         // slave 数量
+	NumOtherSentinels int64         // 监控该 master 的其他哨兵数量
+}
+
+// SentinelReplica SENTINEL REPLICAS(SLAVES) 返回的 slave 信息
+type SentinelReplica struct {
+	SentinelNode
+	MasterAddr       string        // slave 上报的 master 地址(master-host:master-port)
+	MasterLinkStatus string        // 与 master 的连接状态: ok 或 err
+	MasterLinkDown   time.Duration // 与 master 断开连接的时间
+	Priority         int64         // slave-priority, 为 0 时不会被选为 master
+	ReplOffset       int64         // 复制偏移量
+	Lag              int64         // 落后于同一 master 下复制偏移量最大的 slave 的字节数
+}
+
+// SentinelPeer SENTINEL SENTINELS 返回的其他哨兵信息
+type SentinelPeer struct {
+	SentinelNode
+	LastHelloMessage time.Duration // 距离最近一次收到 hello 消息的时间
+	VotedLeader      string        // 最近一次投票选出的 leader run id
+	VotedLeaderEpoch int64         // 最近一次投票的纪元
+}
+
+// sentinelFields 按类型读取哨兵返回的字段,记录第一个解析错误
+type sentinelFields struct {
+	m   map[string]string
+	err error
+}
+
+func (f *sentinelFields) int(key string) int64 {
+	value, ok := f.m[key]
+	if !ok || f.err != nil {
+		return 0
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		f.err = &ParseError{Kind: "sentinel " + key, Input: value, Err: err}
+	}
+	return n
+}
+
+func (f *sentinelFields) ms(key string) time.Duration {
+	return time.Duration(f.int(key)) * time.Millisecond
+}
+
+func (f *sentinelFields) node() SentinelNode {
+	var flags []string
+	if f.m["flags"] != "" {
+		flags = strings.Split(f.m["flags"], ",")
+	}
+	return SentinelNode{
+		Name:            f.m["name"],
+		Addr:            net.JoinHostPort(f.m["ip"], f.m["port"]),
+		RunID:           f.m["runid"],
+		Flags:           flags,
+		LastOkPingReply: f.ms("last-ok-ping-reply"),
+		Raw:             f.m,
+	}
+}
+
+// sentinelMaps 格式化哨兵返回的数组,每个元素为 key、value 交替排列的数组
+func sentinelMaps(ret []interface{}) ([]map[string]string, error) {
+	maps := make([]map[string]string, 0, len(ret))
+	for _, item := range ret {
+		fields, ok := item.([]interface{})
+		if !ok {
+			return nil, &ParseError{Kind: "sentinel reply", Input: fmt.Sprint(item), Err: ErrInvalidFormat}
+		}
+		m := make(map[string]string, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			key, _ := fields[i].(string)
+			value, _ := fields[i+1].(string)
+			m[key] = value
+		}
+		maps = append(maps, m)
+	}
+	return maps, nil
+}
+
+// sentinelAddr 获取哨兵客户端的地址, go-redis 没有提供获取 SentinelClient 连接选项的方法,从 String() 中解析
+func sentinelAddr(rc *redis.SentinelClient) string {
+	s := strings.TrimPrefix(rc.String(), "Redis<")
+	if i := strings.IndexAny(s, " >"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// SentinelMasters 获取哨兵监控的所有 master 信息(SENTINEL MASTERS)
+func SentinelMasters(ctx context.Context, rc *redis.SentinelClient) ([]*SentinelMaster, error) {
+	ret, err := rc.Masters(ctx).Result()
+	if err != nil {
+		return nil, &NodeError{Addr: sentinelAddr(rc), Op: "SENTINEL MASTERS", Err: err}
+	}
+	maps, err := sentinelMaps(ret)
+	if err != nil {
+		return nil, err
+	}
+
+	masters := make([]*SentinelMaster, 0, len(maps))
+	for _, m := range maps {
+		f := &sentinelFields{m: m}
+		master := &SentinelMaster{
+			SentinelNode:      f.node(),
+			Quorum:            f.int("quorum"),
+			DownAfter:         f.ms("down-after-milliseconds"),
+			FailoverTimeout:   f.ms("failover-timeout"),
+			ParallelSyncs:     f.int("parallel-syncs"),
+			ConfigEpoch:       f.int("config-epoch"),
+			NumSlaves:         f.int("num-slaves"),
+			NumOtherSentinels: f.int("num-other-sentinels"),
+		}
+		if f.err != nil {
+			return nil, f.err
+		}
+		masters = append(masters, master)
+	}
+	sort.Slice(masters, func(i, j int) bool { return masters[i].Name < masters[j].Name })
+	return masters, nil
+}
+
+// SentinelReplicas 获取哨兵上 master name 对应的所有 slave 信息(SENTINEL SLAVES),并计算每个 slave 的复制延迟
+func SentinelReplicas(ctx context.Context, rc *redis.SentinelClient, masterName string) ([]*SentinelReplica, error) {
+	ret, err := rc.Slaves(ctx, masterName).Result()
+	if err != nil {
+		return nil, &NodeError{Addr: sentinelAddr(rc), Op: "SENTINEL SLAVES " + masterName, Err: err}
+	}
+	maps, err := sentinelMaps(ret)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]*SentinelReplica, 0, len(maps))
+	var maxOffset int64
+	for _, m := range maps {
+		f := &sentinelFields{m: m}
+		replica := &SentinelReplica{
+			SentinelNode:     f.node(),
+			MasterAddr:       net.JoinHostPort(m["master-host"], m["master-port"]),
+			MasterLinkStatus: m["master-link-status"],
+			MasterLinkDown:   f.ms("master-link-down-time"),
+			Priority:         f.int("slave-priority"),
+			ReplOffset:       f.int("slave-repl-offset"),
+		}
+		if f.err != nil {
+			return nil, f.err
+		}
+		if replica.ReplOffset > maxOffset {
+			maxOffset = replica.ReplOffset
+		}
+		replicas = append(replicas, replica)
+	}
+	for _, replica := range replicas {
+		replica.Lag = maxOffset - replica.ReplOffset
+	}
+	sort.Slice(replicas, func(i, j int) bool { return replicas[i].Addr < replicas[j].Addr })
+	return replicas, nil
+}
+
+// SentinelPeers 获取哨兵上监控 master name 的其他哨兵信息(SENTINEL SENTINELS)
+func SentinelPeers(ctx context.Context, rc *redis.SentinelClient, masterName string) ([]*SentinelPeer, error) {
+	ret, err := rc.Sentinels(ctx, masterName).Result()
+	if err != nil {
+		return nil, &NodeError{Addr: sentinelAddr(rc), Op: "SENTINEL SENTINELS " + masterName, Err: err}
+	}
+	maps, err := sentinelMaps(ret)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make([]*SentinelPeer, 0, len(maps))
+	for _, m := range maps {
+		f := &sentinelFields{m: m}
+		peer := &SentinelPeer{
+			SentinelNode:     f.node(),
+			LastHelloMessage: f.ms("last-hello-message"),
+			VotedLeader:      m["voted-leader"],
+			VotedLeaderEpoch: f.int("voted-leader-epoch"),
+		}
+		if f.err != nil {
+			return nil, f.err
+		}
+		peers = append(peers, peer)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Addr < peers[j].Addr })
+	return peers, nil
+}
+
+// SentinelMasterView 一个哨兵看到的 master 及其 slave 和其他哨兵
+type SentinelMasterView struct {
+	*SentinelMaster
+	Replicas  []*SentinelReplica
+	Sentinels []*SentinelPeer
+}
+
+// SentinelView 一个哨兵看到的所有 master {master name: view}
+type SentinelView struct {
+	Addr    string
+	Masters map[string]*SentinelMasterView
+}
+
+// SentinelDeployment 哨兵部署模型,由所有哨兵各自看到的拓扑组成
+type SentinelDeployment struct {
+	Views  map[string]*SentinelView // 每个可以连接的哨兵的拓扑 {sentinel addr: view}
+	Errors map[string]error         // 获取拓扑失败的哨兵 {sentinel addr: err}
+}
+
+// SentinelAddrs 获取所有哨兵的地址(包括获取拓扑失败的哨兵)
+func (d *SentinelDeployment) SentinelAddrs() []string {
+	addrs := make([]string, 0, len(d.Views)+len(d.Errors))
+	for addr := range d.Views {
+		addrs = append(addrs, addr)
+	}
+	for addr := range d.Errors {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// MasterNames 获取所有哨兵监控的 master name
+func (d *SentinelDeployment) MasterNames() []string {
+	set := make(map[string]bool)
+	for _, view := range d.Views {
+		for name := range view.Masters {
+			set[name] = true
+		}
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Master 获取每个哨兵看到的 master name 的拓扑 {sentinel addr: view}, 没有监控该 master 的哨兵不在结果中
+func (d *SentinelDeployment) Master(masterName string) map[string]*SentinelMasterView {
+	ret := make(map[string]*SentinelMasterView)
+	for addr, view := range d.Views {
+		if m, ok := view.Masters[masterName]; ok {
+			ret[addr] = m
+		}
+	}
+	return ret
+}
+
+// majorityMasterAddr 获取多数哨兵认为的 master 地址,票数相同时取地址较小的, views 为 Master 的返回值
+func majorityMasterAddr(views map[string]*SentinelMasterView) (addr string) {
+	votes := make(map[string]int)
+	for _, view := range views {
+		votes[view.Addr]++
+	}
+	for a, count := range votes {
+		if addr == "" || count > votes[addr] || (count == votes[addr] && a < addr) {
+			addr = a
+		}
+	}
+	return addr
+}
+
+// SentinelTopology 获取哨兵部署模型
+/*
+1.并发连接 addrSlice 中的每个哨兵,获取 SENTINEL MASTERS,以及每个 master 的 SENTINEL SLAVES 和 SENTINEL SENTINELS
+2.通过 SENTINEL SENTINELS 发现的不在 addrSlice 中的哨兵也会被加入,直到没有新的哨兵
+3.连接或获取失败的哨兵记录在 Errors 中,所有哨兵都失败时返回错误
+4.哨兵节点使用 opts.SentinelUsername 和 opts.SentinelPassword 认证, SentinelPassword 为空时使用 Username 和 Password
+*/
+func SentinelTopology(ctx context.Context, addrSlice []string, opts *ConnOptions) (deployment *SentinelDeployment, err error) {
+	if opts == nil {
+		opts = &ConnOptions{}
+	}
+	defer logOperation(opts.logger(), "SentinelTopology", "sentinels", len(addrSlice))(&err)
+
+	deployment = &SentinelDeployment{
+		Views:  make(map[string]*SentinelView),
+		Errors: make(map[string]error),
+	}
+	seen := make(map[string]bool)
+	pending := make([]string, 0, len(addrSlice))
+	for _, addr := range addrSlice {
+		if !seen[addr] {
+			seen[addr] = true
+			pending = append(pending, addr)
+		}
+	}
+
+	var mu sync.Mutex
+	for len(pending) > 0 {
+		eachNode(pending, true, func(i int, addr string) error {
+			view, err := sentinelView(ctx, addr, opts)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				deployment.Errors[addr] = err
+			} else {
+				deployment.Views[addr] = view
+			}
+			return err
+		})
+
+		// 加入新发现的哨兵
+		pending = pending[:0]
+		for _, view := range deployment.Views {
+			for _, master := range view.Masters {
+				for _, peer := range master.Sentinels {
+					if !seen[peer.Addr] {
+						seen[peer.Addr] = true
+						pending = append(pending, peer.Addr)
+					}
+				}
+			}
+		}
+	}
+
+	if len(deployment.Views) == 0 {
+		for _, addr := range addrSlice {
+			if err = deployment.Errors[addr]; err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrNoSentinel
+	}
+	return deployment, nil
+}
+
+// sentinelView 获取单个哨兵看到的拓扑
+func sentinelView(ctx context.Context, addr string, opts *ConnOptions) (*SentinelView, error) {
+	rc, err := initSentinelManagerConn(ctx, addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	masters, err := SentinelMasters(ctx, rc)
+	if err != nil {
+		return nil, err
+	}
+	view := &SentinelView{Addr: addr, Masters: make(map[string]*SentinelMasterView, len(masters))}
+	for _, master := range masters {
+		replicas, err := SentinelReplicas(ctx, rc, master.Name)
+		if err != nil {
+			return nil, err
+		}
+		peers, err := SentinelPeers(ctx, rc, master.Name)
+		if err != nil {
+			return nil, err
+		}
+		view.Masters[master.Name] = &SentinelMasterView{
+			SentinelMaster: master,
+			Replicas:       replicas,
+			Sentinels:      peers,
+		}
+	}
+	return view, nil
+}
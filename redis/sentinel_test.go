@@ -0,0 +1,356 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// fakeRedis 测试用的 redis 服务端, handler 根据命令返回回复: string 为状态回复, []byte 为字符串,
+// int64 为整数, []interface{} 为数组, error 为错误回复, nil 为空回复
+func fakeRedis(t *testing.T, handler func(args []string) interface{}) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeRedis(conn, handler)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveFakeRedis(conn net.Conn, handler func(args []string) interface{}) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readFakeCommand(r)
+		if err != nil {
+			return
+		}
+		var reply interface{} = "OK"
+		switch strings.ToLower(args[0]) {
+		case "hello":
+			reply = errors.New("ERR unknown command")
+		case "ping":
+			reply = "PONG"
+		case "auth", "select":
+		case "client":
+			// 连接初始化时的 CLIENT SETNAME/SETINFO 直接返回 OK, 其他子命令交给 handler
+			if sub := strings.ToLower(args[1]); sub == "kill" || sub == "list" {
+				reply = handler(args)
+			}
+		default:
+			reply = handler(args)
+		}
+		if _, err := conn.Write(encodeFakeReply(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func readFakeCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || line[0] != '*' {
+		return nil, fmt.Errorf("bad command %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func encodeFakeReply(reply interface{}) []byte {
+	switch v := reply.(type) {
+	case nil:
+		return []byte("$-1\r\n")
+	case string:
+		return []byte("+" + v + "\r\n")
+	case []byte:
+		return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(v), v))
+	case int64:
+		return []byte(fmt.Sprintf(":%d\r\n", v))
+	case error:
+		return []byte("-" + v.Error() + "\r\n")
+	case []interface{}:
+		b := []byte(fmt.Sprintf("*%d\r\n", len(v)))
+		for _, item := range v {
+			b = append(b, encodeFakeReply(item)...)
+		}
+		return b
+	}
+	panic(fmt.Sprintf("unsupported reply %T", reply))
+}
+
+// fakeFields 生成哨兵返回的 key、value 交替排列的数组
+func fakeFields(kv ...string) []interface{} {
+	fields := make([]interface{}, len(kv))
+	for i, s := range kv {
+		fields[i] = []byte(s)
+	}
+	return fields
+}
+
+func TestSentinelNodeIsDown(t *testing.T) {
+	tests := []struct {
+		flags []string
+		want  bool
+	}{
+		{flags: nil, want: false},
+		{flags: []string{"master"}, want: false},
+		{flags: []string{"slave", "s_down"}, want: true},
+		{flags: []string{"master", "s_down", "o_down"}, want: true},
+		{flags: []string{"sentinel", "disconnected"}, want: true},
+	}
+	for _, tt := range tests {
+		n := &SentinelNode{Flags: tt.flags}
+		if got := n.IsDown(); got != tt.want {
+			t.Errorf("IsDown(%v) = %v, want %v", tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestSentinelMaps(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply []interface{}
+		want  []map[string]string
+		err   error
+	}{
+		{name: "empty", reply: nil, want: []map[string]string{}},
+		{
+			name:  "fields",
+			reply: []interface{}{[]interface{}{"name", "mymaster", "ip", "10.0.0.1"}, []interface{}{"name", "other"}},
+			want:  []map[string]string{{"name": "mymaster", "ip": "10.0.0.1"}, {"name": "other"}},
+		},
+		{
+			name:  "odd fields",
+			reply: []interface{}{[]interface{}{"name", "mymaster", "ip"}},
+			want:  []map[string]string{{"name": "mymaster"}},
+		},
+		{name: "not array", reply: []interface{}{"name"}, err: ErrInvalidFormat},
+	}
+	for _, tt := range tests {
+		got, err := sentinelMaps(tt.reply)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: sentinelMaps() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSentinelFields(t *testing.T) {
+	f := &sentinelFields{m: map[string]string{
+		"name": "mymaster", "ip": "10.0.0.1", "port": "6379", "runid": "abc",
+		"flags": "master,s_down", "last-ok-ping-reply": "250", "quorum": "2",
+	}}
+	want := SentinelNode{
+		Name: "mymaster", Addr: "10.0.0.1:6379", RunID: "abc", Flags: []string{"master", "s_down"},
+		LastOkPingReply: 250 * time.Millisecond, Raw: f.m,
+	}
+	if got := f.node(); !reflect.DeepEqual(got, want) {
+		t.Errorf("node() = %+v, want %+v", got, want)
+	}
+	if got := f.int("quorum"); got != 2 {
+		t.Errorf("int(quorum) = %d, want 2", got)
+	}
+	if got := f.int("missing"); got != 0 || f.err != nil {
+		t.Errorf("int(missing) = %d, err = %v, want 0, nil", got, f.err)
+	}
+
+	f.m["num-slaves"] = "x"
+	f.int("num-slaves")
+	var parseErr *ParseError
+	if !errors.As(f.err, &parseErr) || parseErr.Kind != "sentinel num-slaves" || parseErr.Input != "x" {
+		t.Errorf("err = %v, want ParseError for sentinel num-slaves", f.err)
+	}
+}
+
+func TestSentinelMasters(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply []interface{}
+		want  []*SentinelMaster
+		err   error
+	}{
+		{
+			name: "masters sorted by name",
+			reply: []interface{}{
+				fakeFields("name", "m2", "ip", "10.0.0.2", "port", "6380", "flags", "master", "quorum", "2",
+					"down-after-milliseconds", "30000", "num-slaves", "1"),
+				fakeFields("name", "m1", "ip", "10.0.0.1", "port", "6379", "flags", "master,o_down", "config-epoch", "3"),
+			},
+			want: []*SentinelMaster{
+				{SentinelNode: SentinelNode{Name: "m1", Addr: "10.0.0.1:6379", Flags: []string{"master", "o_down"}}, ConfigEpoch: 3},
+				{SentinelNode: SentinelNode{Name: "m2", Addr: "10.0.0.2:6380", Flags: []string{"master"}},
+					Quorum: 2, DownAfter: 30 * time.Second, NumSlaves: 1},
+			},
+		},
+		{
+			name:  "bad number",
+			reply: []interface{}{fakeFields("name", "m1", "quorum", "two")},
+			err:   strconv.ErrSyntax,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := fakeRedis(t, func(args []string) interface{} { return tt.reply })
+			rc := redis.NewSentinelClient(&redis.Options{Addr: addr})
+			defer rc.Close()
+
+			got, err := SentinelMasters(context.Background(), rc)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			for _, master := range got {
+				master.Raw = nil
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SentinelMasters() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSentinelReplicas(t *testing.T) {
+	var gotArgs []string
+	addr := fakeRedis(t, func(args []string) interface{} {
+		gotArgs = args
+		return []interface{}{
+			fakeFields("ip", "10.0.0.3", "port", "6379", "flags", "slave", "master-host", "10.0.0.1", "master-port", "6379",
+				"master-link-status", "ok", "slave-priority", "100", "slave-repl-offset", "1000"),
+			fakeFields("ip", "10.0.0.2", "port", "6379", "flags", "slave,disconnected", "master-host", "10.0.0.1", "master-port", "6379",
+				"master-link-status", "err", "master-link-down-time", "5000", "slave-priority", "0", "slave-repl-offset", "400"),
+		}
+	})
+	rc := redis.NewSentinelClient(&redis.Options{Addr: addr})
+	defer rc.Close()
+
+	got, err := SentinelReplicas(context.Background(), rc, "mymaster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"sentinel", "slaves", "mymaster"}; !reflect.DeepEqual(gotArgs, want) {
+		t.Errorf("command = %q, want %q", gotArgs, want)
+	}
+	for _, replica := range got {
+		replica.Raw = nil
+	}
+	want := []*SentinelReplica{
+		{SentinelNode: SentinelNode{Addr: "10.0.0.2:6379", Flags: []string{"slave", "disconnected"}},
+			MasterAddr: "10.0.0.1:6379", MasterLinkStatus: "err", MasterLinkDown: 5 * time.Second, ReplOffset: 400, Lag: 600},
+		{SentinelNode: SentinelNode{Addr: "10.0.0.3:6379", Flags: []string{"slave"}},
+			MasterAddr: "10.0.0.1:6379", MasterLinkStatus: "ok", Priority: 100, ReplOffset: 1000},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SentinelReplicas() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSentinelPeers(t *testing.T) {
+	addr := fakeRedis(t, func(args []string) interface{} {
+		return []interface{}{
+			fakeFields("name", "r1", "ip", "10.0.0.5", "port", "26379", "runid", "r1", "flags", "sentinel",
+				"last-hello-message", "1500", "voted-leader", "?", "voted-leader-epoch", "0"),
+		}
+	})
+	rc := redis.NewSentinelClient(&redis.Options{Addr: addr})
+	defer rc.Close()
+
+	got, err := SentinelPeers(context.Background(), rc, "mymaster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, peer := range got {
+		peer.Raw = nil
+	}
+	want := []*SentinelPeer{
+		{SentinelNode: SentinelNode{Name: "r1", Addr: "10.0.0.5:26379", RunID: "r1", Flags: []string{"sentinel"}},
+			LastHelloMessage: 1500 * time.Millisecond, VotedLeader: "?"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SentinelPeers() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSentinelDeployment(t *testing.T) {
+	m1 := &SentinelMasterView{SentinelMaster: &SentinelMaster{SentinelNode: SentinelNode{Name: "m1"}}}
+	m2 := &SentinelMasterView{SentinelMaster: &SentinelMaster{SentinelNode: SentinelNode{Name: "m2"}}}
+	d := &SentinelDeployment{
+		Views: map[string]*SentinelView{
+			"s2:26379": {Addr: "s2:26379", Masters: map[string]*SentinelMasterView{"m1": m1}},
+			"s1:26379": {Addr: "s1:26379", Masters: map[string]*SentinelMasterView{"m1": m1, "m2": m2}},
+		},
+		Errors: map[string]error{"s3:26379": io.EOF},
+	}
+	if got, want := d.SentinelAddrs(), []string{"s1:26379", "s2:26379", "s3:26379"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SentinelAddrs() = %v, want %v", got, want)
+	}
+	if got, want := d.MasterNames(), []string{"m1", "m2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MasterNames() = %v, want %v", got, want)
+	}
+	if got, want := d.Master("m2"), map[string]*SentinelMasterView{"s1:26379": m2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Master(m2) = %v, want %v", got, want)
+	}
+}
+
+func TestMajorityMasterAddr(t *testing.T) {
+	views := func(addrs ...string) map[string]*SentinelMasterView {
+		ret := make(map[string]*SentinelMasterView)
+		for i, addr := range addrs {
+			ret[fmt.Sprintf("sentinel-%d", i)] = &SentinelMasterView{SentinelMaster: &SentinelMaster{SentinelNode: SentinelNode{Addr: addr}}}
+		}
+		return ret
+	}
+	tests := []struct {
+		name  string
+		views map[string]*SentinelMasterView
+		want  string
+	}{
+		{name: "empty", views: views(), want: ""},
+		{name: "agreed", views: views("10.0.0.1:6379", "10.0.0.1:6379"), want: "10.0.0.1:6379"},
+		{name: "majority", views: views("10.0.0.2:6379", "10.0.0.1:6379", "10.0.0.2:6379"), want: "10.0.0.2:6379"},
+		{name: "tie", views: views("10.0.0.2:6379", "10.0.0.1:6379", "10.0.0.3:6379", "10.0.0.2:6379", "10.0.0.1:6379"), want: "10.0.0.1:6379"},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 10; i++ {
+			if got := majorityMasterAddr(tt.views); got != tt.want {
+				t.Errorf("%s: majorityMasterAddr() = %q, want %q", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
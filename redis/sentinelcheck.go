@@ -0,0 +1,261 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/macoli/gowrapper/table"
+)
+
+// ==================================sentinel check==================================================
+
+// 哨兵检查的问题类型
+const (
+	SentinelIssueUnreachable  = "unreachable"   // 哨兵无法连接或获取拓扑失败
+	SentinelIssueNotMonitored = "not-monitored" // 哨兵没有监控其他哨兵监控的 master
+	SentinelIssueMasterAddr   = "master-addr"   // 哨兵之间 master 地址不一致
+	SentinelIssueConfigEpoch  = "config-epoch"  // 哨兵之间 config-epoch 不一致
+	SentinelIssueQuorumConfig = "quorum-config" // 哨兵之间 quorum 配置不一致,或 quorum 大于哨兵数量
+	SentinelIssueQuorum       = "quorum"        // SENTINEL CKQUORUM 失败,无法达到 quorum 或多数哨兵不可用
+	SentinelIssueSentinels    = "sentinels"     // 哨兵记录的其他哨兵数量与发现的哨兵数量不一致
+	SentinelIssueReplicas     = "replicas"      // 哨兵之间 slave 列表不一致
+	SentinelIssueDown         = "down"          // master 或 slave 被哨兵标记为下线
+)
+
+// SentinelIssue 哨兵检查发现的问题
+type SentinelIssue struct {
+	Master   string // master name, 与 master 无关时为空
+	Sentinel string // 相关的哨兵地址, 涉及多个哨兵时为空
+	Kind     string // 问题类型
+	Message  string
+}
+
+// SentinelMasterReport 单个 master 在所有哨兵上的检查结果
+type SentinelMasterReport struct {
+	Name     string
+	Addr     string              // 多数哨兵认为的 master 地址
+	Addrs    map[string]string   // 每个哨兵认为的 master 地址 {sentinel addr: master addr}
+	Epochs   map[string]int64    // 每个哨兵上的 config-epoch {sentinel addr: epoch}
+	Replicas map[string][]string // 每个哨兵看到的 slave {sentinel addr: [slave addr]}
+	Quorum   map[string]string   // 每个哨兵上 SENTINEL CKQUORUM 的结果 {sentinel addr: reply 或错误}
+	Issues   []*SentinelIssue
+}
+
+// SentinelReport 哨兵部署的健康和一致性检查报告
+type SentinelReport struct {
+	Sentinels  []string                // 所有哨兵地址
+	Masters    []*SentinelMasterReport // 按 master name 排序
+	Issues     []*SentinelIssue        // 所有问题
+	Deployment *SentinelDeployment     // 哨兵部署模型
+}
+
+// Healthy 判断哨兵部署是否没有任何问题
+func (r *SentinelReport) Healthy() bool {
+	return len(r.Issues) == 0
+}
+
+// ShowTable 通过表格展示检查发现的问题
+func (r *SentinelReport) ShowTable() {
+	var rows []interface{}
+	for _, issue := range r.Issues {
+		rows = append(rows, []string{issue.Master, issue.Sentinel, issue.Kind, issue.Message})
+	}
+	headers := []string{"MASTER", "SENTINEL", "KIND", "MESSAGE"}
+	table.ShowTable(table.GenHeaderCellsByNames(headers), table.GenBodyCells(rows))
+}
+
+// SentinelCheck 检查哨兵部署的健康状态和一致性
+/*
+1.通过 SentinelTopology 获取所有哨兵(包括自动发现的哨兵)看到的拓扑
+2.对每个哨兵监控的每个 master 执行 SENTINEL CKQUORUM
+3.对每个 master 比较所有哨兵的视图: 是否都监控了该 master, master 地址、config-epoch、quorum、slave 列表、哨兵数量是否一致
+4.检查被标记为 s_down、o_down、disconnected 的 master 和 slave
+*/
+func SentinelCheck(ctx context.Context, addrSlice []string, opts *ConnOptions) (report *SentinelReport, err error) {
+	if opts == nil {
+		opts = &ConnOptions{}
+	}
+	defer logOperation(opts.logger(), "SentinelCheck", "sentinels", len(addrSlice))(&err)
+
+	deployment, err := SentinelTopology(ctx, addrSlice, opts)
+	if err != nil {
+		return nil, err
+	}
+	report = &SentinelReport{Sentinels: deployment.SentinelAddrs(), Deployment: deployment}
+
+	for _, addr := range report.Sentinels {
+		if sErr, ok := deployment.Errors[addr]; ok {
+			report.Issues = append(report.Issues, &SentinelIssue{
+				Sentinel: addr,
+				Kind:     SentinelIssueUnreachable,
+				Message:  strings.TrimSpace(sErr.Error()),
+			})
+		}
+	}
+
+	quorums := sentinelCkQuorum(ctx, deployment, opts)
+	for _, name := range deployment.MasterNames() {
+		master := checkSentinelMaster(name, deployment, quorums)
+		report.Masters = append(report.Masters, master)
+		report.Issues = append(report.Issues, master.Issues...)
+	}
+	return report, nil
+}
+
+// sentinelCkQuorum 并发在每个哨兵上对其监控的每个 master 执行 SENTINEL CKQUORUM {sentinel addr: {master name: err}}
+func sentinelCkQuorum(ctx context.Context, deployment *SentinelDeployment, opts *ConnOptions) map[string]map[string]error {
+	addrs := make([]string, 0, len(deployment.Views))
+	for addr := range deployment.Views {
+		addrs = append(addrs, addr)
+	}
+
+	var mu sync.Mutex
+	ret := make(map[string]map[string]error, len(addrs))
+	eachNode(addrs, true, func(i int, addr string) error {
+		results := make(map[string]error)
+		rc, err := initSentinelManagerConn(ctx, addr, opts)
+		if err == nil {
+			defer rc.Close()
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		for name := range deployment.Views[addr].Masters {
+			if err != nil {
+				results[name] = err
+				continue
+			}
+			if qErr := rc.CkQuorum(ctx, name).Err(); qErr != nil {
+				results[name] = &NodeError{Addr: addr, Op: "SENTINEL CKQUORUM " + name, Err: qErr}
+			} else {
+				results[name] = nil
+			}
+		}
+
+		mu.Lock()
+		ret[addr] = results
+		mu.Unlock()
+		return nil
+	})
+	return ret
+}
+
+// checkSentinelMaster 比较所有哨兵对 master name 的视图
+func checkSentinelMaster(name string, deployment *SentinelDeployment, quorums map[string]map[string]error) *SentinelMasterReport {
+	views := deployment.Master(name)
+	report := &SentinelMasterReport{
+		Name:     name,
+		Addrs:    make(map[string]string, len(views)),
+		Epochs:   make(map[string]int64, len(views)),
+		Replicas: make(map[string][]string, len(views)),
+		Quorum:   make(map[string]string, len(views)),
+	}
+	addIssue := func(sentinel, kind, msg string) {
+		report.Issues = append(report.Issues, &SentinelIssue{
+			Master:   name,
+			Sentinel: sentinel,
+			Kind:     kind,
+			Message:  msg,
+		})
+	}
+
+	sentinels := make([]string, 0, len(views))
+	for addr := range views {
+		sentinels = append(sentinels, addr)
+	}
+	sort.Strings(sentinels)
+
+	// 没有监控该 master 的哨兵
+	for _, addr := range deployment.SentinelAddrs() {
+		if _, ok := deployment.Views[addr]; !ok {
+			continue
+		}
+		if _, ok := views[addr]; !ok {
+			addIssue(addr, SentinelIssueNotMonitored, message("master_unmonitored", name))
+		}
+	}
+
+	// 收集每个哨兵的视图
+	addrCount := make(map[string]int)
+	quorumSet := make(map[int64]bool)
+	for _, addr := range sentinels {
+		view := views[addr]
+		report.Addrs[addr] = view.Addr
+		report.Epochs[addr] = view.ConfigEpoch
+		addrCount[view.Addr]++
+		quorumSet[view.Quorum] = true
+
+		var replicas []string
+		for _, replica := range view.Replicas {
+			replicas = append(replicas, replica.Addr)
+			if replica.IsDown() {
+				addIssue(addr, SentinelIssueDown, message("node_flagged", "slave", replica.Addr, strings.Join(replica.Flags, ",")))
+			}
+		}
+		report.Replicas[addr] = replicas
+
+		if view.IsDown() {
+			addIssue(addr, SentinelIssueDown, message("node_flagged", "master", view.Addr, strings.Join(view.Flags, ",")))
+		}
+		known := int(view.NumOtherSentinels) + 1
+		if known != len(views) {
+			addIssue(addr, SentinelIssueSentinels, message("sentinel_count", known, len(views)))
+		}
+		if int(view.Quorum) > known {
+			addIssue(addr, SentinelIssueQuorumConfig, message("quorum_too_large", view.Quorum, known))
+		}
+
+		if qErr := quorums[addr][name]; qErr != nil {
+			report.Quorum[addr] = strings.TrimSpace(qErr.Error())
+			addIssue(addr, SentinelIssueQuorum, report.Quorum[addr])
+		} else {
+			report.Quorum[addr] = "OK"
+		}
+	}
+
+	report.Addr = majorityMasterAddr(views)
+	if len(addrCount) > 1 {
+		addIssue("", SentinelIssueMasterAddr, message("master_mismatch", formatNodeValues(report.Addrs)))
+	}
+
+	// config-epoch、quorum、slave 列表是否一致
+	epochs := make(map[string]string, len(report.Epochs))
+	replicas := make(map[string]string, len(report.Replicas))
+	for _, addr := range sentinels {
+		epochs[addr] = fmt.Sprint(report.Epochs[addr])
+		replicas[addr] = strings.Join(report.Replicas[addr], ",")
+	}
+	if !sameStrings(epochs) {
+		addIssue("", SentinelIssueConfigEpoch, message("epoch_mismatch", formatNodeValues(epochs)))
+	}
+	if len(quorumSet) > 1 {
+		quorums := make(map[string]string, len(views))
+		for _, addr := range sentinels {
+			quorums[addr] = fmt.Sprint(views[addr].Quorum)
+		}
+		addIssue("", SentinelIssueQuorumConfig, message("quorum_mismatch", formatNodeValues(quorums)))
+	}
+	if !sameStrings(replicas) {
+		addIssue("", SentinelIssueReplicas, message("replicas_mismatch", formatNodeValues(replicas)))
+	}
+	return report
+}
+
+// sameStrings 判断 map 中所有的值是否相同
+func sameStrings(values map[string]string) bool {
+	first, seen := "", false
+	for _, v := range values {
+		if !seen {
+			first, seen = v, true
+			continue
+		}
+		if v != first {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package redis
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testMasterView 生成测试用的哨兵视图, 所有哨兵看到的 slave 相同
+func testMasterView(addr string, epoch, quorum, others int64, flags ...string) *SentinelMasterView {
+	return &SentinelMasterView{
+		SentinelMaster: &SentinelMaster{
+			SentinelNode:      SentinelNode{Name: "mymaster", Addr: addr, Flags: append([]string{"master"}, flags...)},
+			Quorum:            quorum,
+			ConfigEpoch:       epoch,
+			NumOtherSentinels: others,
+		},
+		Replicas: []*SentinelReplica{{SentinelNode: SentinelNode{Addr: "10.0.0.9:6379", Flags: []string{"slave"}}}},
+	}
+}
+
+// testDeployment 生成测试用的哨兵部署模型 {sentinel addr: view}, view 为 nil 的哨兵没有监控 mymaster
+func testDeployment(views map[string]*SentinelMasterView) *SentinelDeployment {
+	d := &SentinelDeployment{Views: make(map[string]*SentinelView), Errors: map[string]error{}}
+	for addr, view := range views {
+		masters := map[string]*SentinelMasterView{}
+		if view != nil {
+			masters["mymaster"] = view
+		}
+		d.Views[addr] = &SentinelView{Addr: addr, Masters: masters}
+	}
+	return d
+}
+
+func TestCheckSentinelMaster(t *testing.T) {
+	ErrorLang = LangEN
+	defer func() { ErrorLang = LangZH }()
+
+	okQuorum := func(sentinels ...string) map[string]map[string]error {
+		ret := make(map[string]map[string]error)
+		for _, addr := range sentinels {
+			ret[addr] = map[string]error{"mymaster": nil}
+		}
+		return ret
+	}
+	tests := []struct {
+		name     string
+		views    map[string]*SentinelMasterView
+		quorums  map[string]map[string]error
+		addr     string
+		kinds    []string
+		messages []string
+	}{
+		{
+			name: "healthy",
+			views: map[string]*SentinelMasterView{
+				"s1:26379": testMasterView("10.0.0.1:6379", 1, 2, 2),
+				"s2:26379": testMasterView("10.0.0.1:6379", 1, 2, 2),
+				"s3:26379": testMasterView("10.0.0.1:6379", 1, 2, 2),
+			},
+			quorums: okQuorum("s1:26379", "s2:26379", "s3:26379"),
+			addr:    "10.0.0.1:6379",
+		},
+		{
+			name: "quorum",
+			views: map[string]*SentinelMasterView{
+				"s1:26379": testMasterView("10.0.0.1:6379", 1, 3, 1),
+				"s2:26379": testMasterView("10.0.0.1:6379", 1, 3, 1),
+			},
+			quorums: map[string]map[string]error{
+				"s1:26379": {"mymaster": errors.New("NOQUORUM 1 usable Sentinels. Not enough available Sentinels to reach the majority")},
+				"s2:26379": {"mymaster": nil},
+			},
+			addr:  "10.0.0.1:6379",
+			kinds: []string{SentinelIssueQuorumConfig, SentinelIssueQuorum, SentinelIssueQuorumConfig},
+			messages: []string{
+				"quorum 3 is greater than the number of sentinels 2",
+				"NOQUORUM 1 usable Sentinels. Not enough available Sentinels to reach the majority",
+				"quorum 3 is greater than the number of sentinels 2",
+			},
+		},
+		{
+			name: "split views",
+			views: map[string]*SentinelMasterView{
+				"s1:26379": testMasterView("10.0.0.1:6379", 1, 2, 2, "s_down"),
+				"s2:26379": testMasterView("10.0.0.2:6379", 2, 2, 2),
+				"s3:26379": testMasterView("10.0.0.2:6379", 2, 2, 2),
+				"s4:26379": nil,
+			},
+			quorums: okQuorum("s1:26379", "s2:26379", "s3:26379"),
+			addr:    "10.0.0.2:6379",
+			kinds: []string{
+				SentinelIssueNotMonitored, SentinelIssueDown, SentinelIssueMasterAddr, SentinelIssueConfigEpoch,
+			},
+			messages: []string{
+				"sentinel does not monitor master mymaster",
+				"master 10.0.0.1:6379 is flagged as master,s_down",
+				`master address differs between sentinels: s1:26379="10.0.0.1:6379", s2:26379="10.0.0.2:6379", s3:26379="10.0.0.2:6379"`,
+				`config-epoch differs between sentinels: s1:26379="1", s2:26379="2", s3:26379="2"`,
+			},
+		},
+		{
+			name: "tie-break",
+			views: map[string]*SentinelMasterView{
+				"s1:26379": testMasterView("10.0.0.2:6379", 1, 1, 1),
+				"s2:26379": testMasterView("10.0.0.1:6379", 1, 1, 1),
+			},
+			quorums:  okQuorum("s1:26379", "s2:26379"),
+			addr:     "10.0.0.1:6379",
+			kinds:    []string{SentinelIssueMasterAddr},
+			messages: []string{`master address differs between sentinels: s1:26379="10.0.0.2:6379", s2:26379="10.0.0.1:6379"`},
+		},
+	}
+	for _, tt := range tests {
+		report := checkSentinelMaster("mymaster", testDeployment(tt.views), tt.quorums)
+		if report.Addr != tt.addr {
+			t.Errorf("%s: Addr = %s, want %s", tt.name, report.Addr, tt.addr)
+		}
+		var kinds, messages []string
+		for _, issue := range report.Issues {
+			kinds = append(kinds, issue.Kind)
+			messages = append(messages, issue.Message)
+		}
+		if !reflect.DeepEqual(kinds, tt.kinds) || !reflect.DeepEqual(messages, tt.messages) {
+			t.Errorf("%s: issues = %q %q, want %q %q", tt.name, kinds, messages, tt.kinds, tt.messages)
+		}
+	}
+}
@@ -0,0 +1,579 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ==================================sentinel manage==================================================
+
+// SentinelOptions 哨兵管理操作的选项
+type SentinelOptions struct {
+	Conn  *ConnOptions  // 连接选项, 哨兵节点的密码使用 SentinelPassword, 为空时使用 Password
+	Guard *Guard        // 危险操作防护, 确认令牌根据所有哨兵的 run id 生成, 为 nil 时直接执行
+	Wait  time.Duration // FAILOVER 等待完成、RESET 等待重新发现节点的超时时间, 默认 60 秒
+}
+
+// sentinelReadableKeys SENTINEL SET 中可以通过 SENTINEL MASTER 读回的配置项,其他配置项(如 auth-pass)设置后无法校验和回滚
+var sentinelReadableKeys = map[string]bool{
+	"quorum":                  true,
+	"down-after-milliseconds": true,
+	"failover-timeout":        true,
+	"parallel-syncs":          true,
+}
+
+// sentinelConn 哨兵管理连接
+type sentinelConn struct {
+	addr string
+	rc   *redis.SentinelClient
+}
+
+// Do 执行任意命令, SentinelClient 没有提供 Do 方法
+func (c *sentinelConn) Do(ctx context.Context, args ...interface{}) *redis.Cmd {
+	cmd := redis.NewCmd(ctx, args...)
+	_ = c.rc.Process(ctx, cmd)
+	return cmd
+}
+
+// sentinelGroup 一组哨兵的管理连接
+type sentinelGroup struct {
+	opts    *SentinelOptions
+	conns   []*sentinelConn
+	touched []bool // apply 过程中已经部分修改的哨兵, apply 失败时也需要回滚
+}
+
+// withDefaults 返回补全默认值后的选项副本
+func (o *SentinelOptions) withDefaults() *SentinelOptions {
+	ret := &SentinelOptions{}
+	if o != nil {
+		*ret = *o
+	}
+	if ret.Conn == nil {
+		ret.Conn = &ConnOptions{}
+	}
+	if ret.Wait <= 0 {
+		ret.Wait = 60 * time.Second
+	}
+	return ret
+}
+
+// openSentinelGroup 连接所有哨兵,任意一个哨兵连接失败都返回错误, g 不为 nil 时校验确认令牌
+func openSentinelGroup(ctx context.Context, addrSlice []string, opts *SentinelOptions) (*sentinelGroup, error) {
+	if len(addrSlice) == 0 {
+		return nil, ErrNoSentinel
+	}
+
+	group := &sentinelGroup{opts: opts}
+	for _, addr := range addrSlice {
+		rc, err := initSentinelManagerConn(ctx, addr, opts.Conn)
+		if err != nil {
+			group.Close()
+			return nil, err
+		}
+		group.conns = append(group.conns, &sentinelConn{addr: addr, rc: rc})
+	}
+
+	if opts.Guard != nil {
+		ids, err := group.runIDs(ctx)
+		if err != nil {
+			group.Close()
+			return nil, err
+		}
+		if err = opts.Guard.verify(ids); err != nil {
+			group.Close()
+			return nil, err
+		}
+	}
+	return group, nil
+}
+
+// Close 关闭所有哨兵连接
+func (s *sentinelGroup) Close() {
+	for _, c := range s.conns {
+		c.rc.Close()
+	}
+}
+
+// addrs 获取所有哨兵地址
+func (s *sentinelGroup) addrs() []string {
+	addrs := make([]string, 0, len(s.conns))
+	for _, c := range s.conns {
+		addrs = append(addrs, c.addr)
+	}
+	return addrs
+}
+
+// dryRun 判断是否为演练模式
+func (s *sentinelGroup) dryRun() bool {
+	return s.opts.Guard != nil && s.opts.Guard.DryRun
+}
+
+// runIDs 通过 INFO server 获取每个哨兵的 run id
+func (s *sentinelGroup) runIDs(ctx context.Context) ([]string, error) {
+	ids := make([]string, 0, len(s.conns))
+	for _, c := range s.conns {
+		infoStr, err := c.Do(ctx, "info", "server").Text()
+		if err != nil {
+			return nil, &NodeError{Addr: c.addr, Op: "INFO server", Err: err}
+		}
+		infoMap, err := InfoMap(infoStr)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, infoMap["run_id"])
+	}
+	return ids, nil
+}
+
+// do 在哨兵上执行管理命令,支持演练模式和审计日志
+func (s *sentinelGroup) do(ctx context.Context, c *sentinelConn, args ...interface{}) error {
+	err := s.opts.Guard.do(ctx, c, c.addr, args...)
+	if err != nil {
+		return &NodeError{Addr: c.addr, Op: formatSentinelOp(args), Err: err}
+	}
+	return nil
+}
+
+// formatSentinelOp 格式化哨兵命令作为错误中的操作名称,不包含配置项的值
+func formatSentinelOp(args []interface{}) string {
+	if len(args) > 3 {
+		args = args[:3]
+	}
+	return formatArgs(args)
+}
+
+// master 获取哨兵上 master name 的信息(SENTINEL MASTER)
+func (s *sentinelGroup) master(ctx context.Context, c *sentinelConn, masterName string) (map[string]string, error) {
+	ret, err := c.rc.Master(ctx, masterName).Result()
+	if err != nil {
+		return nil, &NodeError{Addr: c.addr, Op: "SENTINEL MASTER " + masterName, Err: err}
+	}
+	return ret, nil
+}
+
+// apply 按顺序在每个哨兵上执行 apply,全部成功后校验,任意一步失败时对已执行的哨兵执行 rollback
+/*
+1.verify 为 nil 或演练模式下不校验
+2.rollback 为 nil 时不回滚; ctx 可能已经取消,回滚使用新的 ctx
+3.apply 由多条命令组成时,在第一条命令成功后调用 s.touch(i),该哨兵后续命令失败时也会回滚
+4.回滚失败时返回 *RollbackError
+*/
+func (s *sentinelGroup) apply(ctx context.Context, apply, verify, rollback func(ctx context.Context, i int, c *sentinelConn) error) error {
+	l := s.opts.Conn.logger()
+	addrs := s.addrs()
+	s.touched = make([]bool, len(addrs))
+	applied, err := eachNode(addrs, false, func(i int, addr string) error {
+		return apply(ctx, i, s.conns[i])
+	})
+	if err == nil && verify != nil && !s.dryRun() {
+		_, err = eachNode(addrs, false, func(i int, addr string) error {
+			return verify(ctx, i, s.conns[i])
+		})
+	}
+	if err == nil || rollback == nil {
+		return err
+	}
+
+	l.Warn("哨兵管理操作失败, 开始回滚", "sentinels", len(addrs), "err", err)
+	rbCtx := context.Background()
+	_, rbErr := eachNode(addrs, false, func(i int, addr string) error {
+		if !applied[i] && !s.touched[i] {
+			return nil
+		}
+		return rollback(rbCtx, i, s.conns[i])
+	})
+	if rbErr != nil {
+		return &RollbackError{Err: err, RollbackErr: rbErr}
+	}
+	return err
+}
+
+// touch 标记第 i 个哨兵已经被部分修改, 只能在 apply 的 apply 函数中调用
+func (s *sentinelGroup) touch(i int) {
+	s.touched[i] = true
+}
+
+// SentinelMonitor 在所有哨兵上监控新的 master
+/*
+1.在每个哨兵上按顺序执行 SENTINEL MONITOR name ip port quorum
+2.settings 不为空时再执行 SENTINEL SET name key value ...(如 down-after-milliseconds、auth-pass)
+3.通过 SENTINEL MASTER 校验每个哨兵上的 master 地址、quorum 和可读回的配置项, masterAddr 为主机名时按解析后的 ip 比较
+4.任意一步失败,在已经执行过的哨兵上执行 SENTINEL REMOVE name, MONITOR 成功但 SET 失败的哨兵也会执行
+*/
+func SentinelMonitor(ctx context.Context, addrSlice []string, masterName, masterAddr string, quorum int, settings map[string]string, opts *SentinelOptions) (err error) {
+	host, port, err := net.SplitHostPort(masterAddr)
+	if err != nil {
+		return &ArgError{Name: "masterAddr", Value: masterAddr, Err: err}
+	}
+
+	opts = opts.withDefaults()
+	defer logOperation(opts.Conn.logger(), "SentinelMonitor", "sentinels", len(addrSlice), "master", masterName, "addr", masterAddr)(&err)
+
+	s, err := openSentinelGroup(ctx, addrSlice, opts)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	expected := map[string]string{"quorum": strconv.Itoa(quorum)}
+	for key, value := range settings {
+		if sentinelReadableKeys[key] {
+			expected[key] = value
+		}
+	}
+
+	return s.apply(ctx,
+		func(ctx context.Context, i int, c *sentinelConn) error {
+			if err := s.do(ctx, c, "sentinel", "monitor", masterName, host, port, quorum); err != nil {
+				return err
+			}
+			s.touch(i)
+			return s.set(ctx, c, masterName, settings)
+		},
+		func(ctx context.Context, i int, c *sentinelConn) error {
+			info, err := s.master(ctx, c, masterName)
+			if err != nil {
+				return err
+			}
+			if addr := net.JoinHostPort(info["ip"], info["port"]); !sameHostPort(ctx, addr, masterAddr) {
+				return &ConfigMismatchError{Key: masterName, Expected: masterAddr, Values: map[string]string{c.addr: addr}}
+			}
+			return sentinelVerify(c.addr, expected, info)
+		},
+		func(ctx context.Context, i int, c *sentinelConn) error {
+			return s.do(ctx, c, "sentinel", "remove", masterName)
+		},
+	)
+}
+
+// sameHostPort 判断两个 host:port 地址是否指向同一个节点: 端口相同,且主机名相同或解析后有相同的 ip
+func sameHostPort(ctx context.Context, a, b string) bool {
+	if a == b {
+		return true
+	}
+	hostA, portA, errA := net.SplitHostPort(a)
+	hostB, portB, errB := net.SplitHostPort(b)
+	if errA != nil || errB != nil || portA != portB {
+		return false
+	}
+	ipsA, errA := net.DefaultResolver.LookupHost(ctx, hostA)
+	ipsB, errB := net.DefaultResolver.LookupHost(ctx, hostB)
+	if errA != nil || errB != nil {
+		return false
+	}
+	for _, ipA := range ipsA {
+		for _, ipB := range ipsB {
+			if net.ParseIP(ipA).Equal(net.ParseIP(ipB)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SentinelRemove 在所有哨兵上停止监控 master
+/*
+1.记录每个哨兵上 master 的地址、quorum 和可读回的配置项
+2.在每个哨兵上按顺序执行 SENTINEL REMOVE name, 并校验 SENTINEL MASTER 已经查不到该 master
+3.任意一步失败,在已经执行过的哨兵上重新 MONITOR 并恢复记录的配置项(auth-pass 等无法读回的配置项不会恢复)
+*/
+func SentinelRemove(ctx context.Context, addrSlice []string, masterName string, opts *SentinelOptions) (err error) {
+	opts = opts.withDefaults()
+	defer logOperation(opts.Conn.logger(), "SentinelRemove", "sentinels", len(addrSlice), "master", masterName)(&err)
+
+	s, err := openSentinelGroup(ctx, addrSlice, opts)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	olds := make([]map[string]string, len(s.conns))
+	for i, c := range s.conns {
+		if olds[i], err = s.master(ctx, c, masterName); err != nil {
+			return err
+		}
+	}
+
+	return s.apply(ctx,
+		func(ctx context.Context, i int, c *sentinelConn) error {
+			return s.do(ctx, c, "sentinel", "remove", masterName)
+		},
+		func(ctx context.Context, i int, c *sentinelConn) error {
+			_, err := c.rc.Master(ctx, masterName).Result()
+			var redisErr redis.Error
+			if errors.As(err, &redisErr) { // No such master with that name
+				return nil
+			}
+			if err != nil {
+				return &NodeError{Addr: c.addr, Op: "SENTINEL MASTER " + masterName, Err: err}
+			}
+			return &ConfigMismatchError{Key: masterName, Expected: "removed", Values: map[string]string{c.addr: "monitored"}}
+		},
+		func(ctx context.Context, i int, c *sentinelConn) error {
+			old := olds[i]
+			if err := s.do(ctx, c, "sentinel", "monitor", masterName, old["ip"], old["port"], old["quorum"]); err != nil {
+				return err
+			}
+			return s.set(ctx, c, masterName, sentinelReadable(old))
+		},
+	)
+}
+
+// SentinelSet 在所有哨兵上修改 master 的配置项(SENTINEL SET),如 down-after-milliseconds、parallel-syncs、auth-pass
+/*
+1.记录每个哨兵上可读回的配置项修改前的值
+2.在每个哨兵上按顺序执行 SENTINEL SET name key value ...
+3.通过 SENTINEL MASTER 校验可读回的配置项已经生效
+4.任意一步失败,在已经执行过的哨兵上恢复修改前的值(auth-pass 等无法读回的配置项不会恢复)
+*/
+func SentinelSet(ctx context.Context, addrSlice []string, masterName string, values map[string]string, opts *SentinelOptions) (err error) {
+	opts = opts.withDefaults()
+	defer logOperation(opts.Conn.logger(), "SentinelSet", "sentinels", len(addrSlice), "master", masterName, "keys", configKeys(values))(&err)
+
+	s, err := openSentinelGroup(ctx, addrSlice, opts)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	olds := make([]map[string]string, len(s.conns))
+	for i, c := range s.conns {
+		info, err := s.master(ctx, c, masterName)
+		if err != nil {
+			return err
+		}
+		olds[i] = make(map[string]string)
+		for key := range values {
+			if sentinelReadableKeys[key] {
+				olds[i][key] = info[key]
+			}
+		}
+	}
+
+	return s.apply(ctx,
+		func(ctx context.Context, i int, c *sentinelConn) error {
+			return s.set(ctx, c, masterName, values)
+		},
+		func(ctx context.Context, i int, c *sentinelConn) error {
+			info, err := s.master(ctx, c, masterName)
+			if err != nil {
+				return err
+			}
+			return sentinelVerify(c.addr, values, info)
+		},
+		func(ctx context.Context, i int, c *sentinelConn) error {
+			return s.set(ctx, c, masterName, olds[i])
+		},
+	)
+}
+
+// set 在单个哨兵上通过一条 SENTINEL SET 设置多个配置项
+func (s *sentinelGroup) set(ctx context.Context, c *sentinelConn, masterName string, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	args := []interface{}{"sentinel", "set", masterName}
+	for _, key := range configKeys(values) {
+		args = append(args, key, values[key])
+	}
+	return s.do(ctx, c, args...)
+}
+
+// sentinelReadable 获取 SENTINEL MASTER 结果中可以通过 SENTINEL SET 恢复的配置项, quorum 由 MONITOR 设置
+func sentinelReadable(info map[string]string) map[string]string {
+	ret := make(map[string]string)
+	for key := range sentinelReadableKeys {
+		if value, ok := info[key]; ok && key != "quorum" {
+			ret[key] = value
+		}
+	}
+	return ret
+}
+
+// sentinelVerify 校验 SENTINEL MASTER 读回的配置项是否与设置的值一致,无法读回的配置项不校验
+func sentinelVerify(addr string, values, info map[string]string) error {
+	for key, value := range values {
+		if !sentinelReadableKeys[key] {
+			continue
+		}
+		if info[key] != value {
+			return &ConfigMismatchError{Key: key, Expected: value, Values: map[string]string{addr: info[key]}}
+		}
+	}
+	return nil
+}
+
+// SentinelFailover 对 master 执行故障转移并等待完成,返回新的 master 地址
+/*
+1.在第一个哨兵上执行 SENTINEL FAILOVER name
+2.每秒通过 SENTINEL GET-MASTER-ADDR-BY-NAME 查询所有哨兵,直到所有哨兵都认为 master 已经切换到同一个新地址
+3.超过 opts.Wait 仍未完成时返回错误,故障转移无法回滚
+4.演练模式下只记录命令,返回当前的 master 地址
+*/
+func SentinelFailover(ctx context.Context, addrSlice []string, masterName string, opts *SentinelOptions) (newMaster string, err error) {
+	opts = opts.withDefaults()
+	defer logOperation(opts.Conn.logger(), "SentinelFailover", "sentinels", len(addrSlice), "master", masterName)(&err)
+
+	s, err := openSentinelGroup(ctx, addrSlice, opts)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+
+	first := s.conns[0]
+	oldMaster, err := sentinelMasterAddr(ctx, first, masterName)
+	if err != nil {
+		return "", err
+	}
+	if err = s.do(ctx, first, "sentinel", "failover", masterName); err != nil {
+		return "", err
+	}
+	if s.dryRun() {
+		return oldMaster, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Wait)
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", &NodeError{Addr: first.addr, Op: "SENTINEL FAILOVER " + masterName, Err: ctx.Err()}
+		case <-ticker.C:
+		}
+
+		newMaster = ""
+		done := true
+		for _, c := range s.conns {
+			addr, err := sentinelMasterAddr(ctx, c, masterName)
+			if err != nil || addr == oldMaster || (newMaster != "" && addr != newMaster) {
+				done = false
+				break
+			}
+			newMaster = addr
+		}
+		if done {
+			return newMaster, nil
+		}
+	}
+}
+
+// sentinelMasterAddr 获取哨兵上 master name 当前的 master 地址
+func sentinelMasterAddr(ctx context.Context, c *sentinelConn, masterName string) (string, error) {
+	ret, err := c.rc.GetMasterAddrByName(ctx, masterName).Result()
+	if err != nil {
+		return "", &NodeError{Addr: c.addr, Op: "SENTINEL GET-MASTER-ADDR-BY-NAME " + masterName, Err: err}
+	}
+	if len(ret) != 2 {
+		return "", &ParseError{Addr: c.addr, Kind: "SENTINEL GET-MASTER-ADDR-BY-NAME " + masterName, Input: fmt.Sprint(ret), Err: ErrInvalidFormat}
+	}
+	return net.JoinHostPort(ret[0], ret[1]), nil
+}
+
+// SentinelReset 逐个重置哨兵上匹配 pattern 的 master 状态(SENTINEL RESET)
+/*
+1.每次只重置一个哨兵,重置前记录该哨兵上每个 master 未下线的 slave 数量和其他哨兵数量
+2.重置后每秒查询 SENTINEL MASTERS,等待该哨兵重新发现这些 slave 和其他哨兵后再重置下一个哨兵
+3.超过 opts.Wait 仍未重新发现时返回错误,重置无法回滚
+*/
+func SentinelReset(ctx context.Context, addrSlice []string, pattern string, opts *SentinelOptions) (err error) {
+	opts = opts.withDefaults()
+	defer logOperation(opts.Conn.logger(), "SentinelReset", "sentinels", len(addrSlice), "pattern", pattern)(&err)
+
+	s, err := openSentinelGroup(ctx, addrSlice, opts)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	for _, c := range s.conns {
+		before, err := sentinelHealthyCounts(ctx, c)
+		if err != nil {
+			return err
+		}
+		if err = s.do(ctx, c, "sentinel", "reset", pattern); err != nil {
+			return err
+		}
+		if s.dryRun() {
+			continue
+		}
+		if err = s.waitRediscover(ctx, c, before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sentinelHealthyCounts 获取哨兵上每个 master 未下线的 slave 数量和其他哨兵数量 {master name: [slaves, sentinels]}
+func sentinelHealthyCounts(ctx context.Context, c *sentinelConn) (map[string][2]int64, error) {
+	masters, err := SentinelMasters(ctx, c.rc)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string][2]int64, len(masters))
+	for _, m := range masters {
+		replicas, err := SentinelReplicas(ctx, c.rc, m.Name)
+		if err != nil {
+			return nil, err
+		}
+		peers, err := SentinelPeers(ctx, c.rc, m.Name)
+		if err != nil {
+			return nil, err
+		}
+		var count [2]int64
+		for _, replica := range replicas {
+			if !replica.IsDown() {
+				count[0]++
+			}
+		}
+		for _, peer := range peers {
+			if !peer.IsDown() {
+				count[1]++
+			}
+		}
+		counts[m.Name] = count
+	}
+	return counts, nil
+}
+
+// waitRediscover 等待哨兵重新发现重置前所有未下线的 slave 和其他哨兵
+func (s *sentinelGroup) waitRediscover(ctx context.Context, c *sentinelConn, before map[string][2]int64) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Wait)
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return &NodeError{Addr: c.addr, Op: "SENTINEL RESET", Err: ctx.Err()}
+		case <-ticker.C:
+		}
+
+		after, err := SentinelMasters(ctx, c.rc)
+		if err != nil {
+			continue
+		}
+		current := make(map[string]*SentinelMaster, len(after))
+		for _, m := range after {
+			current[m.Name] = m
+		}
+		done := true
+		for name, count := range before {
+			cur, ok := current[name]
+			if !ok || cur.NumSlaves < count[0] || cur.NumOtherSentinels < count[1] {
+				done = false
+				break
+			}
+		}
+		if done {
+			return nil
+		}
+	}
+}
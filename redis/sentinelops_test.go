@@ -0,0 +1,71 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSentinel 测试用的哨兵, 记录监控的 master, rejectSet 为 true 时 SENTINEL SET 返回错误
+type fakeSentinel struct {
+	mu        sync.Mutex
+	masters   map[string]bool
+	rejectSet bool
+}
+
+func (s *fakeSentinel) handle(args []string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch strings.ToLower(args[0]) + " " + strings.ToLower(args[1]) {
+	case "sentinel monitor":
+		if s.masters[args[2]] {
+			return errors.New("ERR Duplicated master name")
+		}
+		s.masters[args[2]] = true
+		return "OK"
+	case "sentinel set":
+		if s.rejectSet {
+			return errors.New("ERR Invalid argument '" + args[4] + "' for SENTINEL SET '" + args[3] + "'")
+		}
+		return "OK"
+	case "sentinel remove":
+		if !s.masters[args[2]] {
+			return errors.New("ERR No such master with that name")
+		}
+		delete(s.masters, args[2])
+		return "OK"
+	}
+	return errors.New("ERR unknown command '" + args[0] + "'")
+}
+
+func (s *fakeSentinel) monitoring(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.masters[name]
+}
+
+func TestSentinelMonitorRollbackFailedSet(t *testing.T) {
+	sentinels := []*fakeSentinel{
+		{masters: map[string]bool{}},
+		{masters: map[string]bool{}, rejectSet: true},
+		{masters: map[string]bool{}},
+	}
+	var addrs []string
+	for _, s := range sentinels {
+		addrs = append(addrs, fakeRedis(t, s.handle))
+	}
+
+	err := SentinelMonitor(context.Background(), addrs, "mymaster", "127.0.0.1:6379", 2,
+		map[string]string{"down-after-milliseconds": "x"}, nil)
+	var nodeErr *NodeError
+	if !errors.As(err, &nodeErr) || nodeErr.Addr != addrs[1] {
+		t.Fatalf("err = %v, want SENTINEL SET error of %s", err, addrs[1])
+	}
+	for i, s := range sentinels {
+		if s.monitoring("mymaster") {
+			t.Errorf("sentinel %d still monitors mymaster after rollback", i)
+		}
+	}
+}
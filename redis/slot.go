@@ -2,7 +2,6 @@ package redis
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -10,6 +9,11 @@ import (
 	"time"
 )
 
+const (
+	slotCommandTimeout = 5 * time.Second  // 迁移 slot 时每条命令的超时时间
+	slotMigrateTimeout = 10 * time.Second // MIGRATE 命令在节点间传输 key 的超时时间
+)
+
 // SlotCheck 校验 slot 是否合法范围中: 0-16384
 func SlotCheck(slot int64) {
 	if slot < 0 || slot > 16384 {
@@ -37,9 +41,11 @@ func SlotsGetByInstance(data *ClusterInfo, addr string) (slots []int64, err erro
 		if strings.Contains(item, "-") { // 格式化类型: "1-100"
 			start, sErr := strconv.ParseInt(strings.Split(item, "-")[0], 10, 64)
 			end, eErr := strconv.ParseInt(strings.Split(item, "-")[1], 10, 64)
-			if sErr != nil || eErr != nil {
-				errMsg := fmt.Sprintf("格式化 %s 的 slot 信息 %s 失败\n", addr, item)
-				return nil, errors.New(errMsg)
+			if sErr != nil {
+				return nil, &ParseError{Addr: addr, Kind: "slot", Input: item, Err: sErr}
+			}
+			if eErr != nil {
+				return nil, &ParseError{Addr: addr, Kind: "slot", Input: item, Err: eErr}
 			}
 			for i := start; i <= end; i++ {
 				SlotCheck(i)
@@ -49,8 +55,7 @@ func SlotsGetByInstance(data *ClusterInfo, addr string) (slots []int64, err erro
 		} else { // 格式化类型: "1111"
 			slot, err := strconv.ParseInt(item, 10, 64)
 			if err != nil {
-				errMsg := fmt.Sprintf("格式化 %s 的 slot 信息 %s 失败\n", addr, item)
-				return nil, errors.New(errMsg)
+				return nil, &ParseError{Addr: addr, Kind: "slot", Input: item, Err: err}
 			}
 			SlotCheck(slot)
 			slots = append(slots, slot)
@@ -68,46 +73,89 @@ func SlotsGetByInstance(data *ClusterInfo, addr string) (slots []int64, err erro
 5.源节点执行批量迁移 key 的命令 migrate [target ip] [target port] "" 0 [timeout] keys [keys...]
 6.重复执行步骤 4 和 5,直到 slot 的所有数据都迁移到目标节点
 7.向集群内所有主节点发送 cluster setslot [slot] node [target nodeID],以通知 slot 已经分配给了目标节点
+8.每条命令(每次重试)的超时时间为 5s, MIGRATE 为节点间传输超时 10s 再加 5s
 */
 func SlotMove(sourceAddr, targetAddr, password string, slots []int64, count int, data *ClusterInfo) error {
+	return SlotMoveContext(context.Background(), sourceAddr, targetAddr, password, slots, count, data)
+}
+
+// SlotMoveContext 迁移 slot, ctx 取消时停止迁移,已开始迁移的 slot 保持 importing/migrating 状态
+func SlotMoveContext(ctx context.Context, sourceAddr, targetAddr, password string, slots []int64, count int, data *ClusterInfo) error {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.SlotMove(ctx, sourceAddr, targetAddr, slots, count, data, nil)
+}
+
+// SlotMoveWithGuard 迁移 slot,通过 Guard 支持演练模式、确认令牌和审计日志
+func SlotMoveWithGuard(sourceAddr, targetAddr, password string, slots []int64, count int, data *ClusterInfo, g *Guard) error {
+	return SlotMoveWithGuardContext(context.Background(), sourceAddr, targetAddr, password, slots, count, data, g)
+}
+
+// SlotMoveWithGuardContext 迁移 slot,通过 Guard 支持演练模式、确认令牌和审计日志, ctx 取消时停止迁移
+func SlotMoveWithGuardContext(ctx context.Context, sourceAddr, targetAddr, password string, slots []int64, count int, data *ClusterInfo, g *Guard) error {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.SlotMove(ctx, sourceAddr, targetAddr, slots, count, data, g)
+}
+
+// SlotMove 通过连接池迁移 slot,迁移步骤见 SlotMove 函数, g 不为 nil 时支持演练模式、确认令牌和审计日志
+func (p *NodePool) SlotMove(ctx context.Context, sourceAddr, targetAddr string, slots []int64, count int, data *ClusterInfo, g *Guard) (err error) {
+	l := p.logger()
+	defer logOperation(l, "SlotMove", "source", sourceAddr, "target", targetAddr, "slots", len(slots))(&err)
+
+	// 校验确认令牌, 令牌根据集群所有节点(master 和 slave)实时的 ID 计算
+	clusterNodes := append(append([]string(nil), data.Masters...), data.Slaves...)
+	if err := p.verifyCluster(ctx, clusterNodes, g); err != nil {
+		return err
+	}
+
 	// 建立到 sourceAddr 的连接
-	sourceClient, err := InitStandConn(sourceAddr, password)
+	sourceClient, releaseSource, err := p.acquire(ctx, sourceAddr)
 	if err != nil {
 		return err
 	}
+	// 函数结束后释放 redis 连接
+	defer releaseSource()
 
 	// 建立到 targetAddr 的连接
-	targetClient, err := InitStandConn(targetAddr, password)
+	targetClient, releaseTarget, err := p.acquire(ctx, targetAddr)
 	if err != nil {
 		return err
 	}
-
-	// 函数结束后关闭 redis 连接
-	defer sourceClient.Close()
-	defer targetClient.Close()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	defer releaseTarget()
+
+	// doTimeout 在节点上执行一条命令,每次尝试的超时时间为 slotCommandTimeout
+	doTimeout := func(rc doer, addr string, args ...interface{}) error {
+		op := strings.ToUpper(formatArgs(args[:1]))
+		return p.retry(ctx, addr, op, func() error {
+			cmdCtx, cancel := context.WithTimeout(ctx, slotCommandTimeout)
+			defer cancel()
+			return g.do(cmdCtx, rc, addr, args...)
+		})
+	}
 
 	// 迁移 slot
 	for _, slot := range slots {
-		// 打印帮助信息
-		fmt.Printf("Slot %d 开始迁移\n", slot)
-		fmt.Printf("FROM sourceAddr: %s sourceNodeID: %s\n", sourceAddr, data.AddrToID[sourceAddr])
-		fmt.Printf("TO targetAddr: %s targetNodeID: %s\n", targetAddr, data.AddrToID[targetAddr])
+		// migrationError 生成当前 slot 在 phase 阶段的迁移错误
+		migrationError := func(phase, addr, op string, err error) error {
+			return &SlotMigrationError{Slot: slot, Phase: phase, Source: sourceAddr, Target: targetAddr,
+				Err: &NodeError{Addr: addr, Op: op, Err: err}}
+		}
+
+		l.Info("slot 开始迁移", "slot", slot,
+			"source", sourceAddr, "sourceID", data.AddrToID[sourceAddr],
+			"target", targetAddr, "targetID", data.AddrToID[targetAddr])
 
 		// 对目标节点importing 命令: cluster setslot [slot] importing [source nodeID]
-		_, err = targetClient.Do(ctx, "cluster", "setslot", slot, "importing", data.AddrToID[sourceAddr]).Result()
+		err = doTimeout(targetClient, targetAddr, "cluster", "setslot", slot, "importing", data.AddrToID[sourceAddr])
 		if err != nil {
-			errMsg := fmt.Sprintf("在目标节点执行命令:set slot importing 失败, err:%v\n", err)
-			return errors.New(errMsg)
+			return migrationError(SlotPhaseImporting, targetAddr, "CLUSTER SETSLOT IMPORTING", err)
 		}
 
 		// 对源节点 migration 命令: cluster setslot [slot] migrating [target nodeID]
-		_, err = sourceClient.Do(ctx, "cluster", "setslot", slot, "migrating", data.AddrToID[targetAddr]).Result()
+		err = doTimeout(sourceClient, sourceAddr, "cluster", "setslot", slot, "migrating", data.AddrToID[targetAddr])
 		if err != nil {
-			errMsg := fmt.Sprintf("在源节点执行命令:set slot migration 失败, err:%v\n", err)
-			return errors.New(errMsg)
+			return migrationError(SlotPhaseMigrating, sourceAddr, "CLUSTER SETSLOT MIGRATING", err)
 		}
 
 		// 迁移 slot 中的数据
@@ -115,18 +163,45 @@ func SlotMove(sourceAddr, targetAddr, password string, slots []int64, count int,
 		targetIP := strings.Split(targetAddr, ":")[0]
 		targetPort := strings.Split(targetAddr, ":")[1]
 		//循环迁移 slot 的数据到目标节点
+		migrated := 0
 		for {
-			ret := sourceClient.ClusterGetKeysInSlot(ctx, int(slot), count) // 从源节点获取 slot 的 key(批量)
+			batch := count
+			if g != nil && g.DryRun { // 演练模式下 key 不会真正迁移,一次取出 slot 的所有 key 记录迁移命令
+				var total int64
+				err := p.retry(ctx, sourceAddr, "CLUSTER COUNTKEYSINSLOT", func() (err error) {
+					cmdCtx, cancel := context.WithTimeout(ctx, slotCommandTimeout)
+					defer cancel()
+					total, err = sourceClient.ClusterCountKeysInSlot(cmdCtx, int(slot)).Result()
+					return
+				})
+				if err != nil {
+					return migrationError(SlotPhaseGetKeys, sourceAddr, "CLUSTER COUNTKEYSINSLOT", err)
+				}
+				batch = int(total)
+			}
+			var keys []string
+			err := p.retry(ctx, sourceAddr, "CLUSTER GETKEYSINSLOT", func() (err error) {
+				cmdCtx, cancel := context.WithTimeout(ctx, slotCommandTimeout)
+				defer cancel()
+				keys, err = sourceClient.ClusterGetKeysInSlot(cmdCtx, int(slot), batch).Result() // 从源节点获取 slot 的 key(批量)
+				return
+			})
+			if err != nil {
+				return migrationError(SlotPhaseGetKeys, sourceAddr, "CLUSTER GETKEYSINSLOT", err)
+			}
 			// 循环将获取的 key 发往目标 redis 实例
-			for _, key := range ret.Val() {
-				_, err := sourceClient.Migrate(ctx, targetIP, targetPort, key, 0, time.Second*10).Result()
+			// MIGRATE 不是幂等命令,超时时 key 可能已经迁移成功,重试会得到不同的结果,因此只执行一次不重试
+			for _, key := range keys {
+				migrateCtx, cancel := context.WithTimeout(ctx, slotMigrateTimeout+slotCommandTimeout)
+				err := g.do(migrateCtx, sourceClient, sourceAddr, "migrate", targetIP, targetPort, key, 0, slotMigrateTimeout.Milliseconds())
+				cancel()
 				if err != nil {
-					errMsg := fmt.Sprintf("迁移slot: %d 中的数据失败, err:%v\n", slot, err)
-					return errors.New(errMsg)
+					return migrationError(SlotPhaseMigrate, sourceAddr, "MIGRATE", err)
 				}
+				migrated++
 				//fmt.Printf("#")  // 打印迁移 key 进度
 			}
-			if len(ret.Val()) < count {
+			if len(keys) < count || (g != nil && g.DryRun) {
 				//fmt.Printf("\n")
 				break
 			}
@@ -134,21 +209,19 @@ func SlotMove(sourceAddr, targetAddr, password string, slots []int64, count int,
 
 		// 通告集群slot 已经分配给了目标节点,向集群内所有主节点发送命令: cluster setslot [slot] node [target nodeID]
 		for _, addr := range data.Masters {
-			rc, err := InitStandConn(addr, password)
+			rc, release, err := p.acquire(ctx, addr)
 			if err != nil {
-				errMsg := fmt.Sprintf("连接 redis: %s 失败, err:%v\n", addr, err)
-				return errors.New(errMsg)
+				return &SlotMigrationError{Slot: slot, Phase: SlotPhaseNode, Source: sourceAddr, Target: targetAddr, Err: err}
 			}
 
-			_, err = rc.Do(ctx, "cluster", "setslot", slot, "node", data.AddrToID[targetAddr]).Result()
+			err = doTimeout(rc, addr, "cluster", "setslot", slot, "node", data.AddrToID[targetAddr])
+			release()
 			if err != nil {
-				errMsg := fmt.Sprintf("在 redis: %s 上执行命令: cluster setslot 失败, err:%v\n", addr, err)
-				return errors.New(errMsg)
+				return migrationError(SlotPhaseNode, addr, "CLUSTER SETSLOT NODE", err)
 			}
-			rc.Close()
 		}
 
-		fmt.Printf("Slot %d 完成迁移\n", slot)
+		l.Info("slot 完成迁移", "slot", slot, "keys", migrated)
 	}
 	return nil
 }
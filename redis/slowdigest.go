@@ -0,0 +1,264 @@
+package redis
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================================slowlog digest==================================================
+
+// subCommands 有子命令的命令,指纹中包含子命令
+var subCommands = map[string]bool{
+	"ACL": true, "CLIENT": true, "CLUSTER": true, "COMMAND": true, "CONFIG": true, "DEBUG": true,
+	"FUNCTION": true, "LATENCY": true, "MEMORY": true, "MODULE": true, "OBJECT": true, "PUBSUB": true,
+	"SCRIPT": true, "SLOWLOG": true, "XGROUP": true, "XINFO": true,
+}
+
+// noKeyCommands 没有 key 的命令,参数全部作为值处理
+var noKeyCommands = map[string]bool{
+	"ACL": true, "AUTH": true, "BGREWRITEAOF": true, "BGSAVE": true, "CLIENT": true, "CLUSTER": true,
+	"COMMAND": true, "CONFIG": true, "DBSIZE": true, "DEBUG": true, "ECHO": true, "FLUSHALL": true,
+	"FLUSHDB": true, "FUNCTION": true, "HELLO": true, "INFO": true, "KEYS": true, "LATENCY": true,
+	"MODULE": true, "MULTI": true, "EXEC": true, "PING": true, "PUBLISH": true, "PUBSUB": true,
+	"RANDOMKEY": true, "SAVE": true, "SCAN": true, "SCRIPT": true, "SELECT": true, "SLOWLOG": true,
+	"SWAPDB": true, "TIME": true,
+}
+
+// allKeyCommands 所有参数都是 key 的命令
+var allKeyCommands = map[string]bool{
+	"DEL": true, "EXISTS": true, "MGET": true, "PFCOUNT": true, "SDIFF": true, "SINTER": true,
+	"SUNION": true, "TOUCH": true, "UNLINK": true, "WATCH": true,
+}
+
+// evalCommands 执行脚本或函数的命令,第 1 个参数为脚本(函数名),第 2 个参数为 numkeys
+var evalCommands = map[string]bool{
+	"EVAL": true, "EVALSHA": true, "EVAL_RO": true, "EVALSHA_RO": true, "FCALL": true, "FCALL_RO": true,
+}
+
+// pairKeyCommands key、value 交替排列的命令
+var pairKeyCommands = map[string]bool{
+	"MSET": true, "MSETNX": true,
+}
+
+var (
+	uuidPattern      = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	truncatedPattern = regexp.MustCompile(`\.\.\. ?\(\d+ more (bytes|arguments)\)$`)
+	truncatedArgs    = regexp.MustCompile(`^\.\.\. ?\(\d+ more arguments\)$`)
+	hexPattern       = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+)
+
+// keyDelimiters key 中分隔各段的字符
+const keyDelimiters = ":/._-|#{}[]@="
+
+// SlowLogFingerprint 生成命令的指纹: 命令名称(有子命令时包含子命令) + key 模式,参数的值以 ? 代替
+/*
+1.key 中的数字、UUID 和 16 位以上的十六进制段以 ? 代替,如 user:123:profile => user:?:profile
+2.连续相同的 key 模式只保留一个并加上 ...,如 MGET user:1 user:2 => MGET user:? ..., MSET a:1 x a:2 y => MSET a:? ? ...
+3.连续的值只保留一个 ?,如 HSET user:1 name bob age 3 => HSET user:? ?
+4.EVAL、EVALSHA 的脚本以 ? 代替,按 numkeys 识别 key
+5.慢查询日志超过 32 个参数时最后一个参数为 "... (N more arguments)",生成指纹时忽略,与未截断的命令指纹相同
+*/
+func SlowLogFingerprint(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	cmd := strings.ToUpper(args[0])
+	parts := []string{cmd}
+	rest := args[1:]
+	if subCommands[cmd] && len(rest) > 0 {
+		parts = append(parts, strings.ToUpper(rest[0]))
+		rest = rest[1:]
+	}
+
+	var tokens []string
+	add := func(token string) {
+		last := len(tokens) - 1
+		switch {
+		case last >= 0 && token == "?" && tokens[last] == "?":
+		case last >= 1 && tokens[last] == "..." && tokens[last-1] == token:
+		case last >= 0 && token != "?" && tokens[last] == token:
+			tokens = append(tokens, "...")
+		default:
+			tokens = append(tokens, token)
+		}
+	}
+
+	for i := 0; i < len(rest); i++ {
+		if truncatedArgs.MatchString(rest[i]) {
+			continue
+		}
+		switch {
+		case pairKeyCommands[cmd] && i%2 == 0:
+			add(keyPattern(rest[i]) + " ?")
+			i++
+		case isKeyArg(cmd, rest, i):
+			if pattern := keyPattern(rest[i]); pattern != "" {
+				add(pattern)
+			}
+		default:
+			add("?")
+		}
+	}
+	return strings.Join(append(parts, tokens...), " ")
+}
+
+// isKeyArg 判断命令 cmd 的第 i 个参数(不包括命令名称和子命令)是否为 key
+func isKeyArg(cmd string, rest []string, i int) bool {
+	switch {
+	case noKeyCommands[cmd]:
+		return false
+	case allKeyCommands[cmd]:
+		return true
+	case pairKeyCommands[cmd]:
+		return i%2 == 0
+	case evalCommands[cmd]:
+		if i < 2 {
+			return false
+		}
+		numKeys, _ := strconv.Atoi(rest[1])
+		return i-2 < numKeys
+	default:
+		return i == 0
+	}
+}
+
+// keyPattern 将 key 中的数字、UUID 和长十六进制段以 ? 代替
+func keyPattern(key string) string {
+	if truncatedPattern.MatchString(key) {
+		key = truncatedPattern.ReplaceAllString(key, "")
+	}
+	key = uuidPattern.ReplaceAllString(key, "?")
+
+	var b strings.Builder
+	start := 0
+	flush := func(end int) {
+		seg := key[start:end]
+		if isNumber(seg) || hexPattern.MatchString(seg) {
+			seg = "?"
+		}
+		b.WriteString(seg)
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(keyDelimiters, key[i]) >= 0 {
+			flush(i)
+			b.WriteByte(key[i])
+			start = i + 1
+		}
+	}
+	flush(len(key))
+	return b.String()
+}
+
+// isNumber 判断字符串是否为整数
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// SlowLogDigest 同一指纹的慢查询汇总
+type SlowLogDigest struct {
+	Fingerprint string
+	Command     string         // 命令名称
+	Count       int            // 慢查询次数
+	Total       time.Duration  // 总耗时
+	Avg         time.Duration  // 平均耗时
+	P99         time.Duration  // 99 分位耗时
+	Max         time.Duration  // 最大耗时
+	Min         time.Duration  // 最小耗时
+	First       time.Time      // 最早的执行时间
+	Last        time.Time      // 最近的执行时间
+	Instances   map[string]int // 每个实例上的慢查询次数 {addr: count}
+	Example     SlowLog        // 耗时最长的一条慢查询
+}
+
+// SlowLogAggregate 按指纹汇总慢查询,结果按总耗时倒序排列
+func SlowLogAggregate(logs []SlowLog) []*SlowLogDigest {
+	digests := make(map[string]*SlowLogDigest)
+	durations := make(map[string][]time.Duration)
+	for _, item := range logs {
+		args := item.Args
+		if args == nil {
+			args = strings.Fields(item.Command)
+		}
+		fp := SlowLogFingerprint(args)
+
+		d, ok := digests[fp]
+		if !ok {
+			d = &SlowLogDigest{Fingerprint: fp, Instances: make(map[string]int)}
+			if len(args) > 0 {
+				d.Command = strings.ToUpper(args[0])
+			}
+			digests[fp] = d
+		}
+		d.Count++
+		d.Total += item.Duration
+		d.Instances[item.Instance]++
+		if d.Count == 1 || item.Duration > d.Max {
+			d.Max = item.Duration
+			d.Example = item
+		}
+		if d.Count == 1 || item.Duration < d.Min {
+			d.Min = item.Duration
+		}
+		if !item.Timestamp.IsZero() {
+			if d.First.IsZero() || item.Timestamp.Before(d.First) {
+				d.First = item.Timestamp
+			}
+			if item.Timestamp.After(d.Last) {
+				d.Last = item.Timestamp
+			}
+		}
+		durations[fp] = append(durations[fp], item.Duration)
+	}
+
+	ret := make([]*SlowLogDigest, 0, len(digests))
+	for fp, d := range digests {
+		d.Avg = d.Total / time.Duration(d.Count)
+		d.P99 = percentile(durations[fp], 0.99)
+		ret = append(ret, d)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Total != ret[j].Total {
+			return ret[i].Total > ret[j].Total
+		}
+		return ret[i].Fingerprint < ret[j].Fingerprint
+	})
+	return ret
+}
+
+// Aggregate 按指纹汇总所有节点的慢查询,结果按总耗时倒序排列
+func (r *ClusterSlowLogResult) Aggregate() []*SlowLogDigest {
+	logs := make([]SlowLog, 0, len(r.Entries))
+	for _, entry := range r.Entries {
+		logs = append(logs, entry.SlowLog)
+	}
+	return SlowLogAggregate(logs)
+}
+
+// percentile 按最近秩法计算分位数, q 为 0-1
+func percentile(durations []time.Duration, q float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(q * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
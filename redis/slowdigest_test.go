@@ -0,0 +1,104 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSlowLogFingerprint(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: nil, want: ""},
+		{args: []string{"get", "user:123:profile"}, want: "GET user:?:profile"},
+		{args: []string{"GET", "session:0b6f2a4c-8e1d-4f3a-9c7b-2d5e8f1a3b6c"}, want: "GET session:?"},
+		{args: []string{"GET", "cache:a1b2c3d4e5f60718"}, want: "GET cache:?"},
+		{args: []string{"GET", "v2.config"}, want: "GET v2.config"},
+		{args: []string{"HSET", "user:1", "name", "bob", "age", "3"}, want: "HSET user:? ?"},
+		{args: []string{"MGET", "user:1", "user:2", "user:3"}, want: "MGET user:? ..."},
+		{args: []string{"DEL", "a:1", "b:2"}, want: "DEL a:? b:?"},
+		{args: []string{"MSET", "a:1", "x", "a:2", "y"}, want: "MSET a:? ? ..."},
+		{args: []string{"EVAL", "return 1", "2", "k:1", "k:2", "arg"}, want: "EVAL ? k:? ... ?"},
+		{args: []string{"EVALSHA", "abc", "0", "arg1", "arg2"}, want: "EVALSHA ?"},
+		{args: []string{"config", "set", "maxmemory", "1gb"}, want: "CONFIG SET ?"},
+		{args: []string{"CLIENT", "LIST"}, want: "CLIENT LIST"},
+		{args: []string{"KEYS", "user:*"}, want: "KEYS ?"},
+		{args: []string{"SET", "big:1... (100 more bytes)", "v"}, want: "SET big:? ?"},
+		{args: []string{"DEL", "k:1", "k:2", "... (10 more arguments)"}, want: "DEL k:? ..."},
+		{args: []string{"HSET", "h:1", "f", "v", "...(3 more arguments)"}, want: "HSET h:? ?"},
+	}
+	for _, tt := range tests {
+		if got := SlowLogFingerprint(tt.args); got != tt.want {
+			t.Errorf("SlowLogFingerprint(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestSlowLogAggregate(t *testing.T) {
+	t1 := time.Unix(1700000000, 0)
+	t2 := t1.Add(time.Minute)
+	t3 := t1.Add(2 * time.Minute)
+	logs := []SlowLog{
+		{Instance: "a:6379", Args: []string{"GET", "user:1"}, Duration: 10 * time.Millisecond, Timestamp: t2},
+		{Instance: "b:6379", Args: []string{"GET", "user:2"}, Duration: 30 * time.Millisecond, Timestamp: t1},
+		{Instance: "a:6379", Args: []string{"GET", "user:3"}, Duration: 20 * time.Millisecond, Timestamp: t3},
+		{Instance: "a:6379", Command: "KEYS *", Duration: 100 * time.Millisecond},
+		{Instance: "b:6379", Args: []string{"HGETALL", "h:1"}, Duration: 60 * time.Millisecond, Timestamp: t1},
+	}
+
+	got := SlowLogAggregate(logs)
+	want := []*SlowLogDigest{
+		{
+			Fingerprint: "KEYS ?", Command: "KEYS", Count: 1, Total: 100 * time.Millisecond, Avg: 100 * time.Millisecond,
+			P99: 100 * time.Millisecond, Max: 100 * time.Millisecond, Min: 100 * time.Millisecond,
+			Instances: map[string]int{"a:6379": 1}, Example: logs[3],
+		},
+		{
+			Fingerprint: "GET user:?", Command: "GET", Count: 3, Total: 60 * time.Millisecond, Avg: 20 * time.Millisecond,
+			P99: 30 * time.Millisecond, Max: 30 * time.Millisecond, Min: 10 * time.Millisecond, First: t1, Last: t3,
+			Instances: map[string]int{"a:6379": 2, "b:6379": 1}, Example: logs[1],
+		},
+		{
+			Fingerprint: "HGETALL h:?", Command: "HGETALL", Count: 1, Total: 60 * time.Millisecond, Avg: 60 * time.Millisecond,
+			P99: 60 * time.Millisecond, Max: 60 * time.Millisecond, Min: 60 * time.Millisecond, First: t1, Last: t1,
+			Instances: map[string]int{"b:6379": 1}, Example: logs[4],
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		for i := range got {
+			t.Logf("got[%d] = %+v", i, got[i])
+		}
+		t.Errorf("SlowLogAggregate() differs from want %+v", want)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	ms := func(values ...int) []time.Duration {
+		durations := make([]time.Duration, len(values))
+		for i, v := range values {
+			durations[i] = time.Duration(v) * time.Millisecond
+		}
+		return durations
+	}
+	tests := []struct {
+		durations []time.Duration
+		q         float64
+		want      time.Duration
+	}{
+		{durations: nil, q: 0.99, want: 0},
+		{durations: ms(5), q: 0.99, want: 5 * time.Millisecond},
+		{durations: ms(3, 1, 2), q: 0, want: 1 * time.Millisecond},
+		{durations: ms(3, 1, 2), q: 0.5, want: 2 * time.Millisecond},
+		{durations: ms(3, 1, 2), q: 1, want: 3 * time.Millisecond},
+		{durations: ms(10, 9, 8, 7, 6, 5, 4, 3, 2, 1), q: 0.9, want: 9 * time.Millisecond},
+		{durations: ms(10, 9, 8, 7, 6, 5, 4, 3, 2, 1), q: 0.99, want: 10 * time.Millisecond},
+		{durations: ms(1, 2), q: 1.5, want: 2 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.durations, tt.q); got != tt.want {
+			t.Errorf("percentile(%v, %v) = %v, want %v", tt.durations, tt.q, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package redis
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/macoli/gowrapper/table"
+)
+
+// ==================================slowlog export==================================================
+
+// 慢查询日志导出的列
+const (
+	SlowLogColumnID          = "id"
+	SlowLogColumnTime        = "time"
+	SlowLogColumnInstance    = "instance"
+	SlowLogColumnDuration    = "duration"
+	SlowLogColumnCommand     = "command"
+	SlowLogColumnFingerprint = "fingerprint"
+	SlowLogColumnClientAddr  = "client_addr"
+	SlowLogColumnClientName  = "client_name"
+)
+
+// 慢查询日志导出的排序方式
+const (
+	SlowLogSortNone     = ""         // 保持原有顺序
+	SlowLogSortDuration = "duration" // 按执行耗时排序
+	SlowLogSortTime     = "time"     // 按执行时间排序
+)
+
+// DefaultSlowLogColumns 默认导出的列
+var DefaultSlowLogColumns = []string{
+	SlowLogColumnTime, SlowLogColumnInstance, SlowLogColumnDuration, SlowLogColumnCommand,
+	SlowLogColumnClientAddr, SlowLogColumnClientName,
+}
+
+// SlowLogExportOptions 慢查询日志导出选项
+type SlowLogExportOptions struct {
+	Columns       []string // 导出的列, 为空时使用 DefaultSlowLogColumns
+	SortBy        string   // 排序方式 SlowLogSortDuration、SlowLogSortTime, 为空时保持原有顺序
+	Asc           bool     // 是否从小到大排序, 默认从大到小(耗时最长、最新的在前)
+	MaxCommandLen int      // command 列的最大字符数, 超过时截断并加上 ..., 小于等于 0 时不截断
+	Redact        bool     // 是否隐藏参数的值, 只保留命令名称、子命令和 key, 值以 ? 代替
+}
+
+// slowLogColumnNames 所有可以导出的列
+var slowLogColumnNames = map[string]bool{
+	SlowLogColumnID: true, SlowLogColumnTime: true, SlowLogColumnInstance: true, SlowLogColumnDuration: true,
+	SlowLogColumnCommand: true, SlowLogColumnFingerprint: true, SlowLogColumnClientAddr: true, SlowLogColumnClientName: true,
+}
+
+// prepare 检查导出选项,返回导出的列和排序后的慢查询日志(不修改 logs)
+func (o *SlowLogExportOptions) prepare(logs []SlowLog) ([]string, []SlowLog, error) {
+	if o == nil {
+		o = &SlowLogExportOptions{}
+	}
+	columns := o.Columns
+	if len(columns) == 0 {
+		columns = DefaultSlowLogColumns
+	}
+	for _, column := range columns {
+		if !slowLogColumnNames[column] {
+			return nil, nil, &ArgError{Name: "Columns", Value: column, Err: ErrUnsupported}
+		}
+	}
+
+	sorted := append([]SlowLog(nil), logs...)
+	var less func(a, b SlowLog) bool
+	switch o.SortBy {
+	case SlowLogSortNone:
+	case SlowLogSortDuration:
+		less = func(a, b SlowLog) bool { return a.Duration < b.Duration }
+	case SlowLogSortTime:
+		less = func(a, b SlowLog) bool { return a.Timestamp.Before(b.Timestamp) }
+	default:
+		return nil, nil, &ArgError{Name: "SortBy", Value: o.SortBy, Err: ErrUnsupported}
+	}
+	if less != nil {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if o.Asc {
+				return less(sorted[i], sorted[j])
+			}
+			return less(sorted[j], sorted[i])
+		})
+	}
+
+	if o.Redact || o.MaxCommandLen > 0 {
+		for i := range sorted {
+			item := &sorted[i]
+			args := item.Args
+			if args == nil {
+				args = strings.Fields(item.Command)
+			}
+			if o.Redact {
+				args = RedactArgs(args)
+				item.Args = args
+				item.Command = strings.Join(args, " ")
+			}
+			item.Command = truncateString(item.Command, o.MaxCommandLen)
+		}
+	}
+	return columns, sorted, nil
+}
+
+// RedactArgs 隐藏命令参数的值,只保留命令名称、子命令和 key,值以 ? 代替(EVAL 等命令保留 numkeys),如 SET user:1 secret EX 10 => SET user:1 ? ? ?
+func RedactArgs(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	cmd := strings.ToUpper(args[0])
+	ret := []string{args[0]}
+	rest := args[1:]
+	if subCommands[cmd] && len(rest) > 0 {
+		ret = append(ret, rest[0])
+		rest = rest[1:]
+	}
+	for i, arg := range rest {
+		if isKeyArg(cmd, rest, i) || (evalCommands[cmd] && i == 1) {
+			ret = append(ret, arg)
+		} else {
+			ret = append(ret, "?")
+		}
+	}
+	return ret
+}
+
+// truncateString 截断超过 max 个字符的字符串并加上 ..., max 小于等于 0 时不截断
+func truncateString(s string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max]) + "..."
+}
+
+// slowLogValue 获取慢查询日志列的值, 耗时为微秒, 时间为 RFC3339 格式
+func slowLogValue(item SlowLog, column string) interface{} {
+	switch column {
+	case SlowLogColumnID:
+		return item.ID
+	case SlowLogColumnTime:
+		if item.Timestamp.IsZero() {
+			return item.Time
+		}
+		return item.Timestamp.Format(time.RFC3339)
+	case SlowLogColumnInstance:
+		return item.Instance
+	case SlowLogColumnDuration:
+		return item.Duration.Microseconds()
+	case SlowLogColumnCommand:
+		return item.Command
+	case SlowLogColumnFingerprint:
+		args := item.Args
+		if args == nil {
+			args = strings.Fields(item.Command)
+		}
+		return SlowLogFingerprint(args)
+	case SlowLogColumnClientAddr:
+		return item.ClientAddr
+	case SlowLogColumnClientName:
+		return item.ClientName
+	}
+	return nil
+}
+
+// SlowLogJSONLines 将慢查询日志以 JSON Lines 格式写入 w,每行一条日志, duration 单位为微秒
+func SlowLogJSONLines(w io.Writer, logs []SlowLog, opts *SlowLogExportOptions) error {
+	columns, logs, err := opts.prepare(logs)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	for _, item := range logs {
+		record := make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			record[column] = slowLogValue(item, column)
+		}
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SlowLogCSV 将慢查询日志以 CSV 格式写入 w,第一行为列名, duration 单位为微秒
+func SlowLogCSV(w io.Writer, logs []SlowLog, opts *SlowLogExportOptions) error {
+	columns, logs, err := opts.prepare(logs)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, item := range logs {
+		row := make([]string, 0, len(columns))
+		for _, column := range columns {
+			row = append(row, fmt.Sprint(slowLogValue(item, column)))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// SlowLogShowTable 通过表格展示慢查询日志
+func SlowLogShowTable(logs []SlowLog, opts *SlowLogExportOptions) error {
+	columns, logs, err := opts.prepare(logs)
+	if err != nil {
+		return err
+	}
+	var rows []interface{}
+	for _, item := range logs {
+		row := make([]string, 0, len(columns))
+		for _, column := range columns {
+			switch column {
+			case SlowLogColumnDuration:
+				row = append(row, item.Duration.String())
+			case SlowLogColumnTime:
+				if item.Timestamp.IsZero() {
+					row = append(row, item.Time)
+				} else {
+					row = append(row, item.Timestamp.Format("2006-01-02 15:04:05"))
+				}
+			case SlowLogColumnID:
+				row = append(row, strconv.FormatInt(item.ID, 10))
+			default:
+				row = append(row, fmt.Sprint(slowLogValue(item, column)))
+			}
+		}
+		rows = append(rows, row)
+	}
+	headers := make([]string, 0, len(columns))
+	for _, column := range columns {
+		headers = append(headers, strings.ToUpper(column))
+	}
+	table.ShowTable(table.GenHeaderCellsByNames(headers), table.GenBodyCells(rows))
+	return nil
+}
@@ -0,0 +1,97 @@
+package redis
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRedactArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{args: nil, want: nil},
+		{args: []string{"PING"}, want: []string{"PING"}},
+		{args: []string{"SET", "user:1", "secret", "EX", "10"}, want: []string{"SET", "user:1", "?", "?", "?"}},
+		{args: []string{"AUTH", "admin", "pass"}, want: []string{"AUTH", "?", "?"}},
+		{args: []string{"CONFIG", "SET", "requirepass", "pass"}, want: []string{"CONFIG", "SET", "?", "?"}},
+		{args: []string{"MSET", "a", "1", "b", "2"}, want: []string{"MSET", "a", "?", "b", "?"}},
+		{args: []string{"DEL", "a", "b"}, want: []string{"DEL", "a", "b"}},
+		{args: []string{"EVAL", "return 1", "1", "k", "v"}, want: []string{"EVAL", "?", "1", "k", "?"}},
+	}
+	for _, tt := range tests {
+		if got := RedactArgs(tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("RedactArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{s: "GET key", max: 0, want: "GET key"},
+		{s: "GET key", max: -1, want: "GET key"},
+		{s: "GET key", max: 7, want: "GET key"},
+		{s: "GET key", max: 3, want: "GET..."},
+		{s: "GET 用户:1", max: 5, want: "GET 用..."},
+	}
+	for _, tt := range tests {
+		if got := truncateString(tt.s, tt.max); got != tt.want {
+			t.Errorf("truncateString(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestSlowLogCSV(t *testing.T) {
+	t1 := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+	logs := []SlowLog{
+		{ID: 1, Instance: "a:6379", Args: []string{"SET", "user:1", "secret"}, Command: "SET user:1 secret",
+			Duration: 1500 * time.Microsecond, Timestamp: t1},
+		{ID: 2, Instance: "b:6379", Args: []string{"GET", "user:2"}, Command: "GET user:2",
+			Duration: 3 * time.Millisecond, Timestamp: t1.Add(time.Second)},
+	}
+	tests := []struct {
+		name string
+		opts *SlowLogExportOptions
+		want string
+		err  error
+	}{
+		{
+			name: "sort by duration",
+			opts: &SlowLogExportOptions{Columns: []string{"id", "duration", "command"}, SortBy: SlowLogSortDuration},
+			want: "id,duration,command\n2,3000,GET user:2\n1,1500,SET user:1 secret\n",
+		},
+		{
+			name: "sort by time asc",
+			opts: &SlowLogExportOptions{Columns: []string{"time", "fingerprint"}, SortBy: SlowLogSortTime, Asc: true},
+			want: "time,fingerprint\n2023-11-14T22:13:20Z,SET user:? ?\n2023-11-14T22:13:21Z,GET user:?\n",
+		},
+		{
+			name: "redact and truncate",
+			opts: &SlowLogExportOptions{Columns: []string{"command"}, Redact: true, MaxCommandLen: 8},
+			want: "command\nSET user...\nGET user...\n",
+		},
+		{name: "unknown column", opts: &SlowLogExportOptions{Columns: []string{"args"}}, err: ErrUnsupported},
+		{name: "unknown sort", opts: &SlowLogExportOptions{SortBy: "id"}, err: ErrUnsupported},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		err := SlowLogCSV(&buf, logs, tt.opts)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && buf.String() != tt.want {
+			t.Errorf("%s: SlowLogCSV() = %q, want %q", tt.name, buf.String(), tt.want)
+		}
+	}
+	if logs[0].Command != "SET user:1 secret" {
+		t.Errorf("SlowLogCSV modified logs: %q", logs[0].Command)
+	}
+}
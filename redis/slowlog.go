@@ -2,54 +2,241 @@ package redis
 
 import (
 	"context"
-	"errors"
-	"fmt"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/macoli/gowrapper/slice"
 )
 
+// SlowLog 格式化后的慢查询日志
 type SlowLog struct {
-	Instance string
-	Command  string
-	Duration time.Duration
-	Time     string
+	Instance   string        // redis 实例地址
+	Command    string        // 空格连接的完整命令
+	Duration   time.Duration // 执行耗时
+	Time       string        // 执行时间, 与 Timestamp 相同, 保留用于兼容
+	ID         int64         // 慢查询日志的唯一 ID, 实例重启后从 0 开始
+	Timestamp  time.Time     // 执行时间
+	Args       []string      // 命令及参数, redis 会截断超过 32 个的参数和超过 128 字节的参数
+	ClientAddr string        // 执行命令的客户端地址(ip:port), redis 4.0 及以上版本才有
+	ClientName string        // 执行命令的客户端名称(CLIENT SETNAME), redis 4.0 及以上版本才有
 }
 
 // SlowLogFormat 获取 redis 慢查询并格式化
 func SlowLogFormat(addr string, password string) ([]SlowLog, error) {
-	// 创建 redis 连接
-	rc, err := InitStandConn(addr, password)
+	return SlowLogFormatContext(context.Background(), addr, password)
+}
+
+// SlowLogFormatContext 获取 redis 慢查询并格式化, ctx 用于取消查询
+func SlowLogFormatContext(ctx context.Context, addr string, password string) ([]SlowLog, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.SlowLogFormat(ctx, addr)
+}
+
+// SlowLogFormat 通过连接池获取 redis 慢查询并格式化
+func (p *NodePool) SlowLogFormat(ctx context.Context, addr string) (_ []SlowLog, err error) {
+	defer logOperation(p.logger(), "SlowLogFormat", "addr", addr)(&err)
+
+	// 获取 redis 连接
+	rc, release, err := p.acquire(ctx, addr)
 	if err != nil {
 		return nil, err
 	}
-	defer rc.Close()
+	defer release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	// 获取 redis 慢查询数量
-	nums, err := rc.Do(ctx, "slowlog", "len").Result()
+	var nums interface{}
+	err = p.retry(ctx, addr, "SLOWLOG LEN", func() (err error) {
+		nums, err = rc.Do(ctx, "slowlog", "len").Result()
+		return
+	})
 	if err != nil {
-		errMsg := fmt.Sprintf("获取 redis 实例: %s 的慢查询数量失败, err:%v\n", addr, err)
-		return nil, errors.New(errMsg)
+		return nil, &NodeError{Addr: addr, Op: "SLOWLOG LEN", Err: err}
 	}
 
 	// 获取 redis 的所有慢查询日志
-	ret, err := rc.SlowLogGet(ctx, nums.(int64)).Result()
+	var ret []redis.SlowLog
+	err = p.retry(ctx, addr, "SLOWLOG GET", func() (err error) {
+		ret, err = rc.SlowLogGet(ctx, nums.(int64)).Result()
+		return
+	})
 	if err != nil {
-		errMsg := fmt.Sprintf("获取 redis 实例: %s 的慢查询日志失败, err:%v\n", addr, err)
-		return nil, errors.New(errMsg)
+		return nil, &NodeError{Addr: addr, Op: "SLOWLOG GET", Err: err}
 	}
 
 	// 格式化获取到的慢查询日志
 	var data []SlowLog
 	for _, item := range ret {
 		tmp := SlowLog{
-			addr,
-			strings.Join(item.Args, " "),
-			item.Duration,
-			item.Time.String(),
+			Instance:   addr,
+			Command:    strings.Join(item.Args, " "),
+			Duration:   item.Duration,
+			Time:       item.Time.String(),
+			ID:         item.ID,
+			Timestamp:  item.Time,
+			Args:       item.Args,
+			ClientAddr: item.ClientAddr,
+			ClientName: item.ClientName,
 		}
 		data = append(data, tmp)
 	}
 	return data, err
 }
+
+// ==================================cluster slowlog==================================================
+
+// NodeSlowLog 带节点角色和分片信息的慢查询日志
+type NodeSlowLog struct {
+	SlowLog
+	Role  string // 节点角色: master 或 slave
+	Shard string // 节点所属分片, 为分片 master 的地址
+}
+
+// ClusterSlowLogResult 多个节点的慢查询日志
+type ClusterSlowLogResult struct {
+	Entries []*NodeSlowLog   // 所有节点的慢查询日志, 按执行时间倒序排列(最新的在前)
+	Errors  map[string]error // 获取失败的节点 {addr: err}
+}
+
+// ClusterSlowLog 并发获取集群所有 master 和 slave 的慢查询日志并合并
+func ClusterSlowLog(data *ClusterInfo, password string) (*ClusterSlowLogResult, error) {
+	return ClusterSlowLogContext(context.Background(), data, password)
+}
+
+// ClusterSlowLogContext 并发获取集群所有 master 和 slave 的慢查询日志并合并, ctx 取消时立即返回
+func ClusterSlowLogContext(ctx context.Context, data *ClusterInfo, password string) (*ClusterSlowLogResult, error) {
+	p := passwordPool(password)
+	defer p.Close()
+	return p.ClusterSlowLog(ctx, data)
+}
+
+// ClusterSlowLog 通过连接池并发获取集群所有 master 和 slave 的慢查询日志并合并,
+// 部分节点获取失败时返回其他节点的结果和失败节点的错误,所有节点都失败时返回错误
+func (p *NodePool) ClusterSlowLog(ctx context.Context, data *ClusterInfo) (*ClusterSlowLogResult, error) {
+	return p.slowLogs(ctx, clusterGroupNodes(data))
+}
+
+// SentinelSlowLog 通过哨兵获取 master name 对应的 master 和所有 slave 的慢查询日志并合并
+func SentinelSlowLog(ctx context.Context, sentinelAddrs []string, masterName string, opts *ConnOptions) (*ClusterSlowLogResult, error) {
+	p := NewNodePool(opts)
+	defer p.Close()
+	return p.SentinelSlowLog(ctx, sentinelAddrs, masterName)
+}
+
+// SentinelSlowLog 通过哨兵获取 master name 对应的 master 和所有 slave,并发获取它们的慢查询日志并合并,
+// 哨兵连接使用连接池的连接选项(哨兵密码为 SentinelPassword, 为空时使用 Password)
+func (p *NodePool) SentinelSlowLog(ctx context.Context, sentinelAddrs []string, masterName string) (*ClusterSlowLogResult, error) {
+	nodes, err := p.sentinelGroupNodes(ctx, sentinelAddrs, masterName)
+	if err != nil {
+		return nil, err
+	}
+	return p.slowLogs(ctx, nodes)
+}
+
+// slowLogs 并发获取节点的慢查询日志,合并后按执行时间倒序排列
+func (p *NodePool) slowLogs(ctx context.Context, nodes []groupNode) (ret *ClusterSlowLogResult, err error) {
+	defer logOperation(p.logger(), "ClusterSlowLog", "nodes", len(nodes))(&err)
+
+	addrs := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		addrs = append(addrs, node.addr)
+	}
+	logs := make([][]SlowLog, len(nodes))
+	errs := make([]error, len(nodes))
+	eachNode(addrs, true, func(i int, addr string) error {
+		logs[i], errs[i] = p.SlowLogFormat(ctx, addr)
+		return errs[i]
+	})
+
+	ret = &ClusterSlowLogResult{Errors: make(map[string]error)}
+	for i, node := range nodes {
+		if errs[i] != nil {
+			ret.Errors[node.addr] = errs[i]
+			continue
+		}
+		for _, item := range logs[i] {
+			ret.Entries = append(ret.Entries, &NodeSlowLog{SlowLog: item, Role: node.role, Shard: node.shard})
+		}
+	}
+	if len(nodes) > 0 && len(ret.Errors) == len(nodes) {
+		return nil, errs[0]
+	}
+
+	sort.SliceStable(ret.Entries, func(i, j int) bool {
+		a, b := ret.Entries[i], ret.Entries[j]
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.After(b.Timestamp)
+		}
+		if a.Instance != b.Instance {
+			return a.Instance < b.Instance
+		}
+		return a.ID > b.ID
+	})
+	return ret, nil
+}
+
+// groupNode 集群或哨兵组中的节点
+type groupNode struct {
+	addr  string
+	role  string
+	shard string
+}
+
+// clusterGroupNodes 获取集群所有的 master 和 slave
+func clusterGroupNodes(data *ClusterInfo) (nodes []groupNode) {
+	for _, node := range data.ClusterNodes {
+		switch {
+		case hasFlag(node.Flags, "master"):
+			nodes = append(nodes, groupNode{addr: node.Addr, role: "master", shard: node.Addr})
+		case hasFlag(node.Flags, "slave"):
+			nodes = append(nodes, groupNode{addr: node.Addr, role: "slave", shard: data.IDToAddr[node.MasterID]})
+		}
+	}
+	return nodes
+}
+
+// sentinelGroupNodes 通过哨兵获取 master name 对应的 master 和所有未下线的 slave,
+// 哨兵连接使用连接池的连接选项(哨兵密码为 SentinelPassword, 为空时使用 Password)
+func (p *NodePool) sentinelGroupNodes(ctx context.Context, sentinelAddrs []string, masterName string) ([]groupNode, error) {
+	deployment, err := SentinelTopology(ctx, sentinelAddrs, p.Options)
+	if err != nil {
+		return nil, err
+	}
+	views := deployment.Master(masterName)
+	if len(views) == 0 {
+		return nil, &NodeError{Addr: strings.Join(sentinelAddrs, ","), Op: "SENTINEL MASTER " + masterName, Err: ErrNotMonitored}
+	}
+
+	// 使用多数哨兵认为的 master 地址, slave 列表取自认为该地址是 master 的哨兵中地址最小的一个
+	addr := majorityMasterAddr(views)
+	sentinels := make([]string, 0, len(views))
+	for sentinel := range views {
+		sentinels = append(sentinels, sentinel)
+	}
+	sort.Strings(sentinels)
+	var view *SentinelMasterView
+	for _, sentinel := range sentinels {
+		if views[sentinel].Addr == addr {
+			view = views[sentinel]
+			break
+		}
+	}
+
+	nodes := []groupNode{{addr: view.Addr, role: "master", shard: view.Addr}}
+	for _, replica := range view.Replicas {
+		if !replica.IsDown() {
+			nodes = append(nodes, groupNode{addr: replica.Addr, role: "slave", shard: view.Addr})
+		}
+	}
+	return nodes, nil
+}
+
+// hasFlag 判断节点标志中是否有 flag
+func hasFlag(flags []string, flag string) bool {
+	_, ok := slice.Find(flags, flag)
+	return ok
+}
@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowLogFormat(t *testing.T) {
+	addr := fakeRedis(t, func(args []string) interface{} {
+		switch strings.ToLower(args[1]) {
+		case "len":
+			return int64(2)
+		case "get":
+			return []interface{}{
+				[]interface{}{int64(12), int64(1700000100), int64(25000),
+					fakeFields("HGETALL", "user:1"), []byte("10.0.0.9:51234"), []byte("api")},
+				// redis 4.0 以前的版本没有客户端地址和名称
+				[]interface{}{int64(11), int64(1700000000), int64(1500), fakeFields("KEYS", "*")},
+			}
+		}
+		return nil
+	})
+
+	got, err := SlowLogFormat(addr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts1, ts2 := time.Unix(1700000100, 0), time.Unix(1700000000, 0)
+	want := []SlowLog{
+		{
+			Instance: addr, Command: "HGETALL user:1", Duration: 25 * time.Millisecond, Time: ts1.String(),
+			ID: 12, Timestamp: ts1, Args: []string{"HGETALL", "user:1"}, ClientAddr: "10.0.0.9:51234", ClientName: "api",
+		},
+		{
+			Instance: addr, Command: "KEYS *", Duration: 1500 * time.Microsecond, Time: ts2.String(),
+			ID: 11, Timestamp: ts2, Args: []string{"KEYS", "*"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SlowLogFormat() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,203 @@
+package redis
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ==================================slowlog tail==================================================
+
+// SlowLogTailer 持续获取多个节点的慢查询日志,只投递上次获取之后新增的日志
+/*
+1.按节点记录已投递的最后一条日志 ID,每隔 Interval 获取一次慢查询日志,只投递 ID 更大的日志
+2.节点的 run_id 变化或日志 ID 变小时认为节点已重启,重新从头记录并投递重启后的所有日志,
+  SLOWLOG RESET 不会让日志 ID 变小,清空后没有日志时视为没有新日志
+3.新增的最早一条日志 ID 与上次记录的 ID 不连续时,说明中间的日志被覆盖(超过 slowlog-max-len)或被 SLOWLOG RESET 清空,
+  通过 OnError 报告 *SlowLogGapError,仍投递获取到的日志
+4.同一节点的日志按 ID 从小到大投递,每条日志发送到 channel 后才更新已投递的 ID, ctx 取消时未发送的日志在恢复后重新投递
+*/
+type SlowLogTailer struct {
+	Pool      *NodePool // 获取慢查询日志使用的连接池, 不能为 nil
+	Addrs     []string
+	Interval  time.Duration                // 获取间隔, 小于等于 0 时为 10s
+	Buffer    int                          // 投递日志的 channel 缓冲大小
+	FromStart bool                         // 第一次获取时是否投递节点上已有的日志, 默认只记录最后一条日志的 ID
+	OnError   func(addr string, err error) // 获取失败或日志丢失时调用, 可以为 nil
+
+	mu     sync.Mutex
+	states map[string]*slowLogTailState
+}
+
+// slowLogTailState 单个节点的获取状态
+type slowLogTailState struct {
+	runID  string
+	lastID int64 // 已投递的最后一条日志 ID, -1 表示还没有投递过
+}
+
+// NewSlowLogTailer 创建慢查询日志持续获取器, p 不能为 nil, 为 nil 时每次获取都通过 OnError 报告 *ArgError
+func NewSlowLogTailer(p *NodePool, addrs []string, interval time.Duration) *SlowLogTailer {
+	return &SlowLogTailer{
+		Pool:     p,
+		Addrs:    addrs,
+		Interval: interval,
+		states:   make(map[string]*slowLogTailState),
+	}
+}
+
+// Offsets 获取每个节点已投递的最后一条日志 ID {addr: id},可以保存后通过 SetOffset 恢复
+func (t *SlowLogTailer) Offsets() map[string]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ret := make(map[string]int64, len(t.states))
+	for addr, state := range t.states {
+		ret[addr] = state.lastID
+	}
+	return ret
+}
+
+// SetOffset 设置节点已投递的最后一条日志 ID,从 ID 更大的日志开始投递
+func (t *SlowLogTailer) SetOffset(addr string, id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.states == nil {
+		t.states = make(map[string]*slowLogTailState)
+	}
+	t.states[addr] = &slowLogTailState{lastID: id}
+}
+
+// Run 开始持续获取慢查询日志,返回投递新日志的 channel, ctx 取消后停止获取并关闭 channel
+func (t *SlowLogTailer) Run(ctx context.Context) <-chan SlowLog {
+	interval := t.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	out := make(chan SlowLog, t.Buffer)
+
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if !t.Poll(ctx, out) {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return out
+}
+
+// Poll 并发获取一次所有节点的慢查询日志并将新日志发送到 out, ctx 取消时返回 false
+func (t *SlowLogTailer) Poll(ctx context.Context, out chan<- SlowLog) bool {
+	logs := make([][]SlowLog, len(t.Addrs))
+	eachNode(t.Addrs, true, func(i int, addr string) error {
+		entries, err := t.fetch(ctx, addr)
+		if err != nil {
+			if ctx.Err() == nil {
+				t.report(addr, err)
+			}
+			return err
+		}
+		logs[i] = entries
+		return nil
+	})
+
+	for i, entries := range logs {
+		for _, item := range entries {
+			select {
+			case <-ctx.Done():
+				return false
+			case out <- item:
+			}
+			t.commit(t.Addrs[i], item.ID)
+		}
+	}
+	return ctx.Err() == nil
+}
+
+// commit 记录节点已投递的最后一条日志 ID
+func (t *SlowLogTailer) commit(addr string, id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state, ok := t.states[addr]; ok {
+		state.lastID = id
+	}
+}
+
+// fetch 获取节点上新增的慢查询日志,按 ID 从小到大排列,并更新节点的 run_id, 已投递的 ID 由 Poll 发送后更新
+func (t *SlowLogTailer) fetch(ctx context.Context, addr string) ([]SlowLog, error) {
+	if t.Pool == nil {
+		return nil, &ArgError{Name: "SlowLogTailer.Pool", Value: "nil", Err: ErrPoolRequired}
+	}
+	runID, err := t.Pool.runID(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := t.Pool.SlowLogFormat(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	var maxID int64 = -1
+	if len(entries) > 0 {
+		maxID = entries[len(entries)-1].ID
+	}
+
+	t.mu.Lock()
+	if t.states == nil {
+		t.states = make(map[string]*slowLogTailState)
+	}
+	state, ok := t.states[addr]
+	if !ok {
+		// 第一次获取
+		state = &slowLogTailState{runID: runID, lastID: -1}
+		t.states[addr] = state
+		if !t.FromStart {
+			state.lastID = maxID
+		}
+	}
+	restarted := ok && ((state.runID != "" && state.runID != runID) || (len(entries) > 0 && maxID < state.lastID))
+	if restarted {
+		state.lastID = -1
+	}
+	state.runID = runID
+	lastID := state.lastID
+	t.mu.Unlock()
+
+	if restarted {
+		t.logger().Warn("节点已重启, 重新获取慢查询日志", "addr", addr, "run_id", runID)
+	}
+
+	var ret []SlowLog
+	for _, item := range entries {
+		if item.ID > lastID {
+			ret = append(ret, item)
+		}
+	}
+	if len(ret) > 0 && lastID >= 0 && ret[0].ID > lastID+1 {
+		t.report(addr, &SlowLogGapError{Addr: addr, From: lastID + 1, To: ret[0].ID - 1})
+	}
+	return ret, nil
+}
+
+// report 记录并报告获取失败或日志丢失
+func (t *SlowLogTailer) report(addr string, err error) {
+	t.logger().Warn("持续获取慢查询日志失败", "addr", addr, "err", err)
+	if t.OnError != nil {
+		t.OnError(addr, err)
+	}
+}
+
+// logger 获取连接池的日志, 没有连接池时使用全局日志
+func (t *SlowLogTailer) logger() Logger {
+	if t.Pool == nil {
+		return getLogger()
+	}
+	return t.Pool.logger()
+}
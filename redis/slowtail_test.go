@@ -0,0 +1,133 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSlowLogNode 测试用的 redis 节点, 返回 run_id 和慢查询日志
+type fakeSlowLogNode struct {
+	mu    sync.Mutex
+	runID string
+	ids   []int64 // 节点上保留的慢查询日志 ID
+}
+
+func (n *fakeSlowLogNode) handle(args []string) interface{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	switch strings.ToLower(args[0]) + " " + strings.ToLower(args[1]) {
+	case "info server":
+		return []byte("# Server\r\nrun_id:" + n.runID + "\r\n")
+	case "slowlog len":
+		return int64(len(n.ids))
+	case "slowlog get":
+		// 与 redis 一致, 最新的日志在前
+		var entries []interface{}
+		for i := len(n.ids) - 1; i >= 0; i-- {
+			entries = append(entries, []interface{}{n.ids[i], int64(1700000000), int64(25000), fakeFields("GET", "k")})
+		}
+		return entries
+	}
+	return errors.New("ERR unknown command '" + args[0] + "'")
+}
+
+func (n *fakeSlowLogNode) set(runID string, ids ...int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.runID, n.ids = runID, ids
+}
+
+func TestSlowLogTailerPoll(t *testing.T) {
+	node := &fakeSlowLogNode{runID: "run-1", ids: []int64{8, 9, 10}}
+	addr := fakeRedis(t, node.handle)
+
+	p := NewNodePool(nil)
+	defer p.Close()
+	var errs []error
+	tailer := NewSlowLogTailer(p, []string{addr}, 0)
+	tailer.OnError = func(_ string, err error) { errs = append(errs, err) }
+
+	poll := func() (ids []int64) {
+		out := make(chan SlowLog, 16)
+		if !tailer.Poll(context.Background(), out) {
+			t.Fatal("Poll() = false, want true")
+		}
+		close(out)
+		for item := range out {
+			ids = append(ids, item.ID)
+		}
+		return
+	}
+	tests := []struct {
+		name  string
+		runID string
+		ids   []int64
+		want  []int64
+		gap   *SlowLogGapError
+	}{
+		{name: "first poll skips existing logs", runID: "run-1", ids: []int64{8, 9, 10}},
+		{name: "new logs", runID: "run-1", ids: []int64{9, 10, 11, 12}, want: []int64{11, 12}},
+		{name: "no new logs", runID: "run-1", ids: []int64{11, 12}},
+		{name: "reset without new logs", runID: "run-1", ids: nil},
+		{
+			name: "overwritten logs", runID: "run-1", ids: []int64{15, 16}, want: []int64{15, 16},
+			gap: &SlowLogGapError{Addr: addr, From: 13, To: 14},
+		},
+		{name: "restarted", runID: "run-2", ids: []int64{0, 1}, want: []int64{0, 1}},
+		{name: "id went back", runID: "run-2", ids: []int64{0}, want: []int64{0}},
+	}
+	for _, tt := range tests {
+		node.set(tt.runID, tt.ids...)
+		errs = nil
+		if got := poll(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: delivered %v, want %v", tt.name, got, tt.want)
+		}
+		var wantErrs []error
+		if tt.gap != nil {
+			wantErrs = []error{tt.gap}
+		}
+		if !reflect.DeepEqual(errs, wantErrs) {
+			t.Errorf("%s: errors = %v, want %v", tt.name, errs, wantErrs)
+		}
+	}
+	if got := tailer.Offsets(); got[addr] != 0 {
+		t.Errorf("Offsets() = %v, want %s at 0", got, addr)
+	}
+}
+
+func TestSlowLogTailerSetOffset(t *testing.T) {
+	node := &fakeSlowLogNode{runID: "run-1", ids: []int64{8, 9, 10}}
+	addr := fakeRedis(t, node.handle)
+
+	p := NewNodePool(nil)
+	defer p.Close()
+	tailer := NewSlowLogTailer(p, []string{addr}, 0)
+	tailer.SetOffset(addr, 8)
+
+	out := make(chan SlowLog, 16)
+	tailer.Poll(context.Background(), out)
+	close(out)
+	var got []int64
+	for item := range out {
+		got = append(got, item.ID)
+	}
+	if want := []int64{9, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("delivered %v, want %v", got, want)
+	}
+}
+
+func TestSlowLogTailerNilPool(t *testing.T) {
+	var errs []error
+	tailer := &SlowLogTailer{Addrs: []string{"127.0.0.1:1"}, OnError: func(_ string, err error) { errs = append(errs, err) }}
+	if !tailer.Poll(context.Background(), make(chan SlowLog)) {
+		t.Fatal("Poll() = false, want true")
+	}
+	var argErr *ArgError
+	if len(errs) != 1 || !errors.As(errs[0], &argErr) || !errors.Is(errs[0], ErrPoolRequired) {
+		t.Errorf("errors = %v, want *ArgError of %v", errs, ErrPoolRequired)
+	}
+}
@@ -0,0 +1,268 @@
+package redis
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ==================================redis uri==================================================
+
+// 连接模式
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+)
+
+// URIConfig 解析 redis URI 得到的连接配置
+/*
+支持的格式:
+redis://[user:password@]host[:port][/db][?query]
+rediss://[user:password@]host[:port][/db][?query]                      --TLS 连接
+redis-sentinel://[user:password@]s1[:port],s2[:port]/master[/db][?query] --rediss-sentinel 为 TLS 连接
+redis-cluster://[user:password@]n1[:port],n2[:port][?query]              --rediss-cluster 为 TLS 连接
+
+query 参数:
+dial_timeout、read_timeout、write_timeout: 超时时间,如 5s、500ms,纯数字时单位为秒
+pool_size、min_idle_conns: 连接池大小和最小空闲连接数
+client_name: 连接名称
+sentinel_username、sentinel_password: 哨兵节点的 ACL 用户名和密码
+replica: 哨兵模式下为 true 时连接 slave(只读)
+tls_ca、tls_cert、tls_key、tls_server_name、tls_insecure_skip_verify: TLS 选项
+*/
+type URIConfig struct {
+	Mode       string       // 连接模式: standalone、sentinel、cluster
+	Addrs      []string     // 单实例为一个地址,哨兵模式为哨兵地址,集群模式为集群节点地址
+	MasterName string       // 哨兵模式下的 master name
+	Replica    bool         // 哨兵模式下是否连接 slave
+	Options    *ConnOptions // 连接选项
+}
+
+// uriSchemes 支持的 scheme 对应的连接模式和是否使用 TLS
+var uriSchemes = map[string]struct {
+	mode string
+	tls  bool
+}{
+	"redis":           {ModeStandalone, false},
+	"rediss":          {ModeStandalone, true},
+	"redis-sentinel":  {ModeSentinel, false},
+	"rediss-sentinel": {ModeSentinel, true},
+	"redis-cluster":   {ModeCluster, false},
+	"rediss-cluster":  {ModeCluster, true},
+}
+
+// ParseURI 解析 redis URI
+func ParseURI(uri string) (*URIConfig, error) {
+	idx := strings.Index(uri, "://")
+	if idx < 0 {
+		return nil, uriError(uri, ErrURINoScheme)
+	}
+	scheme, rest := strings.ToLower(uri[:idx]), uri[idx+3:]
+	schemeInfo, ok := uriSchemes[scheme]
+	if !ok {
+		return nil, uriError(uri, &ArgError{Name: "scheme", Value: scheme, Err: ErrUnsupported})
+	}
+	conf := &URIConfig{Mode: schemeInfo.mode, Options: &ConnOptions{}}
+
+	// 拆分 query
+	var rawQuery string
+	if i := strings.Index(rest, "?"); i >= 0 {
+		rest, rawQuery = rest[:i], rest[i+1:]
+	}
+
+	// 拆分 host 列表和 path
+	authority, path := rest, ""
+	if i := strings.Index(rest, "/"); i >= 0 {
+		authority, path = rest[:i], rest[i+1:]
+	}
+
+	// 解析用户名和密码
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		userInfo := authority[:i]
+		authority = authority[i+1:]
+		user, password := userInfo, ""
+		if j := strings.Index(userInfo, ":"); j >= 0 {
+			user, password = userInfo[:j], userInfo[j+1:]
+		}
+		var err error
+		if conf.Options.Username, err = url.PathUnescape(user); err != nil {
+			return nil, uriError(uri, err)
+		}
+		if conf.Options.Password, err = url.PathUnescape(password); err != nil {
+			return nil, uriError(uri, err)
+		}
+	}
+
+	// 解析地址,没有端口时使用默认端口
+	defaultPort := "6379"
+	if conf.Mode == ModeSentinel {
+		defaultPort = "26379"
+	}
+	for _, host := range strings.Split(authority, ",") {
+		if host == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), defaultPort)
+		}
+		conf.Addrs = append(conf.Addrs, host)
+	}
+	if len(conf.Addrs) == 0 {
+		return nil, uriError(uri, ErrURINoAddr)
+	}
+	if conf.Mode == ModeStandalone && len(conf.Addrs) > 1 {
+		return nil, uriError(uri, ErrURITooManyAddrs)
+	}
+
+	// 解析 path: 单实例为 /db,哨兵为 /master[/db],集群没有 path
+	segments := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	if conf.Mode == ModeSentinel {
+		if len(segments) == 0 {
+			return nil, uriError(uri, ErrURINoMasterName)
+		}
+		conf.MasterName, segments = segments[0], segments[1:]
+	}
+	if len(segments) > 1 || (conf.Mode == ModeCluster && len(segments) > 0) {
+		return nil, uriError(uri, &ArgError{Name: "path", Value: "/" + path, Err: ErrInvalidFormat})
+	}
+	if len(segments) == 1 {
+		db, err := strconv.Atoi(segments[0])
+		if err != nil {
+			return nil, uriError(uri, err)
+		}
+		conf.Options.DB = db
+	}
+
+	// 解析 query 参数
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, uriError(uri, err)
+	}
+	if err = conf.applyQuery(query, schemeInfo.tls); err != nil {
+		return nil, uriError(uri, err)
+	}
+	return conf, nil
+}
+
+// applyQuery 将 query 参数设置到连接配置
+func (c *URIConfig) applyQuery(query url.Values, useTLS bool) (err error) {
+	opts := c.Options
+	for key, values := range query {
+		value := values[len(values)-1]
+		switch key {
+		case "dial_timeout":
+			opts.DialTimeout, err = parseURIDuration(value)
+		case "read_timeout":
+			opts.ReadTimeout, err = parseURIDuration(value)
+		case "write_timeout":
+			opts.WriteTimeout, err = parseURIDuration(value)
+		case "pool_size":
+			opts.PoolSize, err = strconv.Atoi(value)
+		case "min_idle_conns":
+			opts.MinIdleConns, err = strconv.Atoi(value)
+		case "client_name":
+			opts.ClientName = value
+		case "sentinel_username":
+			opts.SentinelUsername = value
+		case "sentinel_password":
+			opts.SentinelPassword = value
+		case "replica":
+			c.Replica, err = strconv.ParseBool(value)
+		case "tls_ca", "tls_cert", "tls_key", "tls_server_name", "tls_insecure_skip_verify":
+			useTLS = true
+		default:
+			return &ArgError{Name: key, Value: value, Err: ErrUnsupported}
+		}
+		if err != nil {
+			return &ArgError{Name: key, Value: value, Err: err}
+		}
+	}
+
+	if useTLS {
+		opts.TLS = &TLSOptions{
+			CAFile:     query.Get("tls_ca"),
+			CertFile:   query.Get("tls_cert"),
+			KeyFile:    query.Get("tls_key"),
+			ServerName: query.Get("tls_server_name"),
+		}
+		if v := query.Get("tls_insecure_skip_verify"); v != "" {
+			if opts.TLS.InsecureSkipVerify, err = strconv.ParseBool(v); err != nil {
+				return &ArgError{Name: "tls_insecure_skip_verify", Value: v, Err: err}
+			}
+		}
+		if opts.TLS.ServerName == "" && c.Mode == ModeStandalone {
+			opts.TLS.ServerName, _, _ = net.SplitHostPort(c.Addrs[0])
+		}
+	}
+	if c.Replica && c.Mode != ModeSentinel {
+		return &ArgError{Name: "replica", Value: query.Get("replica"), Err: ErrSentinelOnly}
+	}
+	return nil
+}
+
+// parseURIDuration 解析超时时间,纯数字时单位为秒
+func parseURIDuration(value string) (time.Duration, error) {
+	return ParseDuration(value, time.Second)
+}
+
+// uriError 生成 URI 解析错误,错误信息中隐藏密码
+func uriError(uri string, err error) error {
+	return &URIError{URI: redactURI(uri), Err: err}
+}
+
+// redactURI 隐藏 URI 中的密码
+func redactURI(uri string) string {
+	idx := strings.Index(uri, "://")
+	if idx < 0 {
+		return uri
+	}
+	rest := uri[idx+3:]
+	end := len(rest)
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		end = i
+	}
+	at := strings.LastIndex(rest[:end], "@")
+	if at < 0 {
+		return uri
+	}
+	userInfo := rest[:at]
+	if i := strings.Index(userInfo, ":"); i >= 0 {
+		userInfo = userInfo[:i] + ":xxxxx"
+	}
+	return uri[:idx+3] + userInfo + rest[at:]
+}
+
+// Connect 解析 redis URI,按连接模式建立单实例、哨兵 master/slave 或集群连接
+func Connect(ctx context.Context, uri string) (redis.UniversalClient, error) {
+	conf, err := ParseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return conf.Connect(ctx)
+}
+
+// Connect 按连接配置建立连接
+func (c *URIConfig) Connect(ctx context.Context) (redis.UniversalClient, error) {
+	var rc redis.UniversalClient
+	var err error
+	switch {
+	case c.Mode == ModeSentinel && c.Replica:
+		rc, err = initSentinelSlaveConn(ctx, c.Addrs, c.MasterName, c.Options)
+	case c.Mode == ModeSentinel:
+		rc, err = initSentinelMasterConn(ctx, c.Addrs, c.MasterName, c.Options)
+	case c.Mode == ModeCluster:
+		rc, err = initClusterConn(ctx, c.Addrs, c.Options)
+	default:
+		rc, err = initStandConn(ctx, c.Addrs[0], c.Options)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
@@ -37,6 +37,18 @@ func GenHeaderCells(m interface{}) []*simpletable.Cell {
 	return Cells
 }
 
+// GenHeaderCellsByNames 通过列名生成表头
+func GenHeaderCellsByNames(names []string) []*simpletable.Cell {
+	Cells := []*simpletable.Cell{
+		{Align: simpletable.AlignRight, Text: "ID"},
+	}
+	for _, name := range names {
+		cell := simpletable.Cell{Align: simpletable.AlignCenter, Text: name}
+		Cells = append(Cells, &cell)
+	}
+	return Cells
+}
+
 // GenBodyCells 生成表数据
 func GenBodyCells(m []interface{}) [][]*simpletable.Cell {
 	var Cells [][]*simpletable.Cell